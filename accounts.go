@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// system accounts that exist on debian, ubuntu and rpm based distributions
+var systemAccounts = []string{
+	"root", "daemon", "bin", "sys", "adm", "lp", "mail", "news", "uucp", "proxy", "www-data", "backup",
+	"list", "irc", "nobody", "nogroup", "users", "staff", "wheel", "disk", "tty", "audio", "video",
+	"utmp", "shadow", "systemd-journal", "ftp", "games", "man",
+}
+
+// commands creating users and groups in maintainer scripts
+var accountCommands = []string{"useradd", "adduser", "groupadd", "addgroup", "systemd-sysusers"}
+
+// function createsAccount decides whether a script creates the named user or group
+// lines calling one of the account commands with the name count, as do sysusers lines like "u name"
+// passed to systemd-sysusers in the script
+func createsAccount(path, name string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	sysusers := false
+	named := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		command := false
+		for _, field := range fields {
+			if contains(accountCommands, field) {
+				command = true
+				sysusers = sysusers || field == "systemd-sysusers"
+			}
+		}
+		if command && contains(fields, name) {
+			return true, nil
+		}
+		if len(fields) >= 2 && (fields[0] == "u" || fields[0] == "g") && fields[1] == name {
+			named = true
+		}
+	}
+	return sysusers && named, scanner.Err()
+}
+
+// method checkAccounts verifies that users and groups named by the package exist on the hosts
+//
+// files owned by user and group or by the owners of attributes are unpacked before after_install runs, so the
+// accounts have to be created by before_install unless they are system accounts. the user of a pleaserun service
+// may be created by after_install as well, as the service starts afterwards. known lists further accounts existing
+// on the hosts
+func (p *Package) checkAccounts(known []string) error {
+	type account struct {
+		field, name, creators string
+		scripts               []string
+	}
+	accounts := []account{
+		{"target.user", p.Target.User, "before_install", []string{p.Target.BeforeInstall}},
+		{"target.group", p.Target.Group, "before_install", []string{p.Target.BeforeInstall}},
+	}
+	for i, a := range p.Attributes {
+		accounts = append(accounts,
+			account{fmt.Sprintf("attributes[%d].user", i), a.User, "before_install", []string{p.Target.BeforeInstall}},
+			account{fmt.Sprintf("attributes[%d].group", i), a.Group, "before_install", []string{p.Target.BeforeInstall}})
+	}
+	if p.Source.Mode == "pleaserun" {
+		accounts = append(accounts, account{"source.user", p.Source.User, "before_install or after_install",
+			[]string{p.Target.BeforeInstall, p.Target.AfterInstall}})
+	}
+
+	for _, a := range accounts {
+		if a.name == "" || contains(systemAccounts, a.name) || contains(known, a.name) {
+			continue
+		}
+		created := false
+		for _, s := range a.scripts {
+			if s == "" {
+				continue
+			}
+			if p.templated() {
+				rendered, _, err := p.renderScript(s)
+				if err != nil {
+					return err
+				}
+				defer os.Remove(rendered)
+				s = rendered
+			}
+			ok, err := createsAccount(s, a.name)
+			if err != nil {
+				return err
+			}
+			created = created || ok
+		}
+		if !created {
+			return ConfigError{
+				packageEntry: p.Name,
+				field:        a.field,
+				message: fmt.Sprintf(tr("%s is no system account and is not created by %s "+
+					"(useradd, adduser, groupadd, addgroup or systemd-sysusers)"), a.name, a.creators),
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+)
+
+// apk versions consist of numbers, an optional letter, suffixes and a package release e.g. "1.2.3b_rc1-r0"
+var apkVersionPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)*[a-z]?(_(alpha|beta|pre|rc|cvs|svn|git|hg|p)[0-9]*)*(-r[0-9]+)?$`)
+
+// function gzipMembers splits concatenated gzip streams into their raw bytes
+// apk packages consist of a signature, a control and a data segment, each a separate gzip stream
+func gzipMembers(data []byte) ([][]byte, error) {
+	members := [][]byte{}
+	r := bytes.NewReader(data)
+	for r.Len() > 0 {
+		start := len(data) - r.Len()
+
+		// bytes.Reader is an io.ByteReader, so gzip does not read beyond the end of the member
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		zr.Multistream(false)
+		if _, err := io.Copy(ioutil.Discard, zr); err != nil {
+			return nil, err
+		}
+		members = append(members, data[start:len(data)-r.Len()])
+	}
+	return members, nil
+}
+
+// function apkSegment creates a gzip compressed tar segment of an apk package
+// segments are not terminated by end of archive blocks so they can be concatenated
+func apkSegment(files map[string][]byte, order []string) ([]byte, error) {
+	b := &bytes.Buffer{}
+	zw := gzip.NewWriter(b)
+	tw := tar.NewWriter(zw)
+	for _, name := range order {
+		header := &tar.Header{
+			Name:    name,
+			Mode:    0644,
+			Size:    int64(len(files[name])),
+			ModTime: buildTime(),
+			Format:  tar.FormatUSTAR,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(files[name]); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// method apkControl rewrites the control segment and adds the metadata fpm does not write
+func (p *Package) apkControl(control []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(control))
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string][]byte{}
+	order := []string{}
+	tr := tar.NewReader(zr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		contents, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		files[header.Name] = contents
+		order = append(order, header.Name)
+	}
+
+	pkginfo, ok := files[".PKGINFO"]
+	if !ok {
+		return nil, fmt.Errorf("control segment contains no .PKGINFO")
+	}
+	if p.Target.Origin != "" {
+		pkginfo = append(pkginfo, []byte(fmt.Sprintf("origin = %s\n", p.Target.Origin))...)
+	}
+	if p.Target.Maintainer != "" {
+		pkginfo = append(pkginfo, []byte(fmt.Sprintf("maintainer = %s\n", p.Target.Maintainer))...)
+	}
+	files[".PKGINFO"] = pkginfo
+
+	return apkSegment(files, order)
+}
+
+// function apkSignature creates the signature segment of an apk package over its control segment
+// the signer has to create RSA PKCS #1 v1.5 signatures over the SHA-1 digest like abuild-sign does
+func apkSignature(control []byte, signer Signer, keyName string) ([]byte, error) {
+	signature, err := signer.Sign(bytes.NewReader(control))
+	if err != nil {
+		return nil, err
+	}
+	name := ".SIGN.RSA." + keyName
+	return apkSegment(map[string][]byte{name: signature}, []string{name})
+}
+
+// method finishApk adds origin and maintainer to an apk built by fpm and signs it if a signer is given
+func (p *Package) finishApk(artifact string, signer Signer) error {
+	data, err := ioutil.ReadFile(artifact)
+	if err != nil {
+		return err
+	}
+
+	members, err := gzipMembers(data)
+	if err != nil {
+		return err
+	}
+	if len(members) != 2 {
+		return fmt.Errorf("expected an unsigned apk with control and data segment, found %d segments", len(members))
+	}
+
+	control, err := p.apkControl(members[0])
+	if err != nil {
+		return err
+	}
+
+	apk := append(control, members[1]...)
+	if signer != nil {
+		signature, err := apkSignature(control, signer, p.Target.APKKeyName)
+		if err != nil {
+			return err
+		}
+		apk = append(signature, apk...)
+	}
+
+	return ioutil.WriteFile(artifact, apk, 0644)
+}
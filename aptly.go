@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// aptlyPublisher publishes packages using the REST API of an aptly server
+type aptlyPublisher struct {
+	target *PublishTarget
+}
+
+// method request sends a request to the aptly API and decodes the json response into result
+func (a *aptlyPublisher) request(method, path, contentType string, body io.Reader, result interface{}) error {
+	req, err := http.NewRequest(method, strings.TrimSuffix(a.target.URL, "/")+"/api"+path, body)
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if a.target.Username != "" {
+		req.SetBasicAuth(a.target.Username, a.target.Password)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	contents, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("aptly %s %s failed: %s %s", method, path, resp.Status, contents)
+	}
+	if result != nil {
+		return json.Unmarshal(contents, result)
+	}
+	return nil
+}
+
+// method requestJSON sends a json encoded body to the aptly API
+func (a *aptlyPublisher) requestJSON(method, path string, body interface{}, result interface{}) error {
+	contents, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	return a.request(method, path, "application/json", bytes.NewReader(contents), result)
+}
+
+// method publishPath returns the API path of the published repository of a suite
+// aptly expects "/" in prefixes to be escaped as "_"
+func (a *aptlyPublisher) publishPath(s Suite) string {
+	prefix := a.target.Prefix
+	if prefix == "" {
+		prefix = "."
+	}
+	prefix = strings.Replace(prefix, "_", "__", -1)
+	prefix = strings.Replace(prefix, "/", "_", -1)
+	return fmt.Sprintf("/publish/%s/%s", url.PathEscape(prefix), url.PathEscape(s.Distribution))
+}
+
+// method updatePublished re-publishes a suite so added or removed packages become visible
+func (a *aptlyPublisher) updatePublished(s Suite) error {
+	return a.requestJSON(http.MethodPut, a.publishPath(s), map[string]interface{}{}, nil)
+}
+
+// method Publish uploads a package, adds it to the repository of the suite and updates the publication
+func (a *aptlyPublisher) Publish(artifact string, s Suite) error {
+	f, err := os.Open(artifact)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// upload the file into a temporary upload directory
+	body := &bytes.Buffer{}
+	form := multipart.NewWriter(body)
+	part, err := form.CreateFormFile("file", filepath.Base(artifact))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, f); err != nil {
+		return err
+	}
+	if err := form.Close(); err != nil {
+		return err
+	}
+	dir := strings.TrimSuffix(filepath.Base(artifact), filepath.Ext(artifact))
+	if err := a.request(http.MethodPost, "/files/"+url.PathEscape(dir), form.FormDataContentType(), body, nil); err != nil {
+		return err
+	}
+
+	// add the uploaded file to the repository
+	result := struct {
+		FailedFiles []string
+	}{}
+	if err := a.requestJSON(http.MethodPost, fmt.Sprintf("/repos/%s/file/%s", url.PathEscape(s.Repo), url.PathEscape(dir)), nil, &result); err != nil {
+		return err
+	}
+	if len(result.FailedFiles) > 0 {
+		return fmt.Errorf("aptly could not add %s to %s", strings.Join(result.FailedFiles, ", "), s.Repo)
+	}
+
+	return a.updatePublished(s)
+}
+
+// method Describe implements Publisher
+func (a *aptlyPublisher) Describe(artifact string, s Suite) string {
+	return fmt.Sprintf("upload to %s/api/files, add to repo %s and update the publication %s, a different package of the same name, version and architecture is rejected",
+		strings.TrimSuffix(a.target.URL, "/"), s.Repo, strings.TrimPrefix(a.publishPath(s), "/publish/"))
+}
+
+// method Check implements Publisher
+// aptly has no permissions, reading the repository and the publication verifies the credentials
+func (a *aptlyPublisher) Check(s Suite) error {
+	if err := a.request(http.MethodGet, "/repos/"+url.PathEscape(s.Repo), "", nil, nil); err != nil {
+		return err
+	}
+	published := []struct {
+		Prefix       string
+		Distribution string
+	}{}
+	if err := a.request(http.MethodGet, "/publish", "", nil, &published); err != nil {
+		return err
+	}
+	prefix := a.target.Prefix
+	if prefix == "" {
+		prefix = "."
+	}
+	for _, p := range published {
+		if p.Prefix == prefix && p.Distribution == s.Distribution {
+			return nil
+		}
+	}
+	return fmt.Errorf("aptly has no publication %s/%s", prefix, s.Distribution)
+}
+
+// method Promote moves a package from the repository of one suite into another and updates both publications
+func (a *aptlyPublisher) Promote(p *Package, from, to Suite) error {
+	refs := []string{}
+	query := url.QueryEscape(fmt.Sprintf("Name (= %s), Version (= %s)", p.Name, p.Target.Version))
+	if err := a.request(http.MethodGet, fmt.Sprintf("/repos/%s/packages?q=%s", url.PathEscape(from.Repo), query), "", nil, &refs); err != nil {
+		return err
+	}
+	if len(refs) == 0 {
+		return fmt.Errorf("package %s %s not found in %s", p.Name, p.Target.Version, from.Repo)
+	}
+
+	body := map[string][]string{"PackageRefs": refs}
+	if err := a.requestJSON(http.MethodPost, fmt.Sprintf("/repos/%s/packages", url.PathEscape(to.Repo)), body, nil); err != nil {
+		return err
+	}
+	if err := a.requestJSON(http.MethodDelete, fmt.Sprintf("/repos/%s/packages", url.PathEscape(from.Repo)), body, nil); err != nil {
+		return err
+	}
+
+	if err := a.updatePublished(to); err != nil {
+		return err
+	}
+	return a.updatePublished(from)
+}
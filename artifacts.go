@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// function readChecksums reads a manifest in the format of sha256sum, lines like "<checksum>  <path>"
+// it maps the cleaned paths to their checksums
+func readChecksums(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	manifest := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) != 2 || !sha256Pattern.MatchString(strings.ToLower(fields[0])) {
+			return nil, fmt.Errorf("line %d of %s is not a sha256 checksum followed by a path", n, path)
+		}
+		// sha256sum marks files read in binary mode with *
+		file := filepath.Clean(strings.TrimPrefix(strings.TrimSpace(fields[1]), "*"))
+		if filepath.IsAbs(file) || strings.HasPrefix(file, "..") {
+			return nil, fmt.Errorf("line %d of %s names %s outside of the artifacts directory", n, path, file)
+		}
+		manifest[file] = strings.ToLower(fields[0])
+	}
+	return manifest, scanner.Err()
+}
+
+// function fileChecksum returns the hex encoded sha256 checksum of a file
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// method verifyArtifacts checks the artifacts directory against the manifest and turns the package into a dir package
+//
+// every listed file has to exist with its checksum and every file of the directory has to be listed,
+// so a package never contains a file the build job did not hand over. excluded files are ignored
+func (p *Package) verifyArtifacts() error {
+	manifest, err := readChecksums(p.Source.Manifest)
+	if err != nil {
+		return err
+	}
+
+	// the manifest is not packaged if it is stored along with the artifacts
+	excludes := p.excludes()
+	root, err := filepath.Abs(p.Source.Chdir)
+	if err != nil {
+		return err
+	}
+	if manifestPath, err := filepath.Abs(p.Source.Manifest); err == nil && within(root, manifestPath) {
+		rel, _ := filepath.Rel(root, manifestPath)
+		excludes = append(excludes, rel)
+		p.Source.Excludes = append(append([]string{}, p.Source.Excludes...), rel)
+	}
+
+	problems := []string{}
+	found := map[string]bool{}
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		if excluded(rel, excludes) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		found[rel] = true
+		expected, ok := manifest[rel]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s is not listed in the manifest", rel))
+			return nil
+		}
+		sum, err := fileChecksum(path)
+		if err != nil {
+			return err
+		}
+		if sum != expected {
+			problems = append(problems, fmt.Sprintf("%s has checksum %s, expected %s", rel, sum, expected))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for file := range manifest {
+		if !found[file] && !excluded(file, excludes) {
+			problems = append(problems, fmt.Sprintf("%s is missing", file))
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("\n  %s", strings.Join(problems, "\n  "))
+	}
+
+	fmt.Printf(tr("verified %d artifacts of %s against %s\n"), len(manifest), p.Name, p.Source.Manifest)
+	p.Source.Mode = "dir"
+	if len(p.Paths) == 0 {
+		p.Paths = []string{"."}
+	}
+	return nil
+}
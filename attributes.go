@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// FileAttribute sets the mode and owners of packaged files, e.g. 0755 for binaries or 0600 for configuration with secrets
+type FileAttribute struct {
+	// Path is the absolute path of the installed file, patterns like /usr/bin/* match several files *REQUIRED*
+	Path string `yaml:"path"`
+
+	// Mode is the octal file mode e.g. 0640 *OPTIONAL*
+	Mode string `yaml:"mode"`
+
+	// User and Group owning the file, only for rpm packages *OPTIONAL*
+	// other target modes of the same entry keep the owners, deb packages can only set the owners of all files
+	// with target.user and target.group
+	User  string `yaml:"user"`
+	Group string `yaml:"group"`
+}
+
+// method check validates an attribute, i is its index in attributes
+func (a *FileAttribute) check(p *Package, i int) error {
+	field := fmt.Sprintf("attributes[%d]", i)
+	var message string
+	switch {
+	case !path.IsAbs(a.Path):
+		field, message = field+".path", "the absolute path of the installed file is required"
+	case a.Mode == "" && a.User == "" && a.Group == "":
+		message = "every attribute needs at least one of mode, user and group"
+	case a.Mode != "" && !validMode(a.Mode):
+		field, message = field+".mode", "the file mode must be an octal number like 0755"
+	case (a.User != "" || a.Group != "") && !contains(p.Target.Modes, "rpm"):
+		field, message = field+".user|group", "owners of single files are only available for target mode rpm"
+	}
+	if message == "" {
+		return nil
+	}
+	return ConfigError{
+		packageEntry: p.Name,
+		field:        field,
+		message:      message,
+	}
+}
+
+// function validMode decides whether mode is an octal file mode up to 07777
+func validMode(mode string) bool {
+	m, err := strconv.ParseUint(mode, 8, 32)
+	return err == nil && m <= 07777
+}
+
+// method attributed lists the files of the package with their attributes
+// all attributes matching a file apply, later attributes replace the values of earlier ones
+func (p *Package) attributed() ([]ContentFile, []FileAttribute, error) {
+	files, err := p.contents()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	matched := make([]bool, len(p.Attributes))
+	attributedFiles := []ContentFile{}
+	attributes := []FileAttribute{}
+	for _, f := range files {
+		if f.Info.IsDir() {
+			continue
+		}
+		merged := FileAttribute{Path: f.Target}
+		found := false
+		for i, a := range p.Attributes {
+			if ok, _ := path.Match(a.Path, f.Target); !ok {
+				continue
+			}
+			matched[i], found = true, true
+			if a.Mode != "" {
+				merged.Mode = a.Mode
+			}
+			if a.User != "" {
+				merged.User = a.User
+			}
+			if a.Group != "" {
+				merged.Group = a.Group
+			}
+		}
+		if found {
+			attributedFiles = append(attributedFiles, f)
+			attributes = append(attributes, merged)
+		}
+	}
+
+	// an attribute without files is most likely a typo, the file would keep its mode silently
+	for i, ok := range matched {
+		if !ok {
+			return nil, nil, fmt.Errorf("attribute %s matches no file of the package", p.Attributes[i].Path)
+		}
+	}
+	return attributedFiles, attributes, nil
+}
+
+// method applyAttributes sets the attributes of the files of the package
+//
+// rpm packages declare them with --rpm-attr, the files on disk are left as they are. fpm copies
+// the modes of the files into packages of other target modes, so they are changed on disk while
+// fpm runs. the returned function restores the previous modes
+func (p *Package) applyAttributes() (func(), error) {
+	restore := func() {}
+	if len(p.Attributes) == 0 {
+		return restore, nil
+	}
+	files, attributes, err := p.attributed()
+	if err != nil {
+		return restore, err
+	}
+
+	if p.Target.Mode == "rpm" {
+		p.rpmAttributes = []string{}
+		for _, a := range attributes {
+			values := []string{"-", "-", "-"}
+			for i, v := range []string{a.Mode, a.User, a.Group} {
+				if v != "" {
+					values[i] = v
+				}
+			}
+			p.rpmAttributes = append(p.rpmAttributes, strings.Join(values, ",")+":"+a.Path)
+		}
+		return restore, nil
+	}
+
+	previous := map[string]os.FileMode{}
+	restore = func() {
+		for source, mode := range previous {
+			if err := os.Chmod(source, mode); err != nil {
+				fmt.Printf(tr("could not restore the mode of %s: %s\n"), source, err)
+			}
+		}
+	}
+	for i, f := range files {
+		if attributes[i].Mode == "" || f.Info.Mode()&os.ModeSymlink != 0 {
+			continue
+		}
+		mode, _ := strconv.ParseUint(attributes[i].Mode, 8, 32)
+		if _, ok := previous[f.Source]; !ok {
+			previous[f.Source] = f.Info.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+		}
+		if err := os.Chmod(f.Source, fileMode(mode)); err != nil {
+			restore()
+			return func() {}, err
+		}
+	}
+	return restore, nil
+}
+
+// function fileMode converts the octal permission bits of a file mode to an os.FileMode
+func fileMode(mode uint64) os.FileMode {
+	m := os.FileMode(mode) & os.ModePerm
+	for bit, flag := range map[uint64]os.FileMode{04000: os.ModeSetuid, 02000: os.ModeSetgid, 01000: os.ModeSticky} {
+		if mode&bit != 0 {
+			m |= flag
+		}
+	}
+	return m
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+// stages of building a package in the order they run
+// staging prepares the sources, fpm includes compressing the package, verification runs all checks afterwards
+var benchStages = []string{"staging", "fpm", "verification"}
+
+// compressors probed on the contents of every package, only those installed are used
+var benchCompressors = [][]string{
+	{"gzip", "-6"},
+	{"bzip2", "-9"},
+	{"xz", "-6", "-T0"},
+	{"zstd", "-19", "-T0"},
+}
+
+// method stopwatch returns a function adding the time since its last call to a stage of the package
+// nothing is recorded unless the run is a benchmark
+func (c *FPMConfig) stopwatch(r *PackageReport) func(stage string) {
+	last := time.Now()
+	return func(stage string) {
+		if !c.bench {
+			return
+		}
+		if r.Timings == nil {
+			r.Timings = map[string]float64{}
+		}
+		r.Timings[stage] += time.Since(last).Seconds()
+		last = time.Now()
+	}
+}
+
+// function durationStats returns the minimum, mean and maximum of the values
+func durationStats(values []float64) (float64, float64, float64) {
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	sum := 0.0
+	for _, v := range sorted {
+		sum += v
+	}
+	return sorted[0], sum / float64(len(sorted)), sorted[len(sorted)-1]
+}
+
+// countingWriter counts the bytes written to it, e.g. by a compressor
+type countingWriter struct {
+	n int64
+}
+
+// method Write implements io.Writer
+func (w *countingWriter) Write(b []byte) (int, error) {
+	w.n += int64(len(b))
+	return len(b), nil
+}
+
+// method probeCompression compresses a tarball of the package contents with a compressor
+// it returns the time taken, the size of the tarball and the compressed size
+func (p *Package) probeCompression(compressor []string) (time.Duration, int64, int64, error) {
+	files, err := p.contents()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	compressed := &countingWriter{}
+	cmd := exec.Command(compressor[0], append(compressor[1:], "-c")...)
+	cmd.Stdout = compressed
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return 0, 0, 0, err
+	}
+
+	raw := &countingWriter{}
+	archive := tar.NewWriter(io.MultiWriter(stdin, raw))
+	for _, f := range files {
+		if !f.Info.Mode().IsRegular() {
+			continue
+		}
+		header, err := tar.FileInfoHeader(f.Info, "")
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		header.Name = strings.TrimPrefix(f.Target, "/")
+		if err := archive.WriteHeader(header); err != nil {
+			return 0, 0, 0, err
+		}
+		in, err := os.Open(f.Source)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		_, err = io.Copy(archive, in)
+		in.Close()
+		if err != nil {
+			return 0, 0, 0, err
+		}
+	}
+	archive.Close()
+	stdin.Close()
+	if err := cmd.Wait(); err != nil {
+		return 0, 0, 0, err
+	}
+	return time.Since(start), raw.n, compressed.n, nil
+}
+
+// method benchmark builds all packages repeatedly and prints how long every stage took
+// packages whose contents are known are compressed with all installed compressors to compare them
+// artifacts of all but the last run are removed, nothing is published
+func (c *FPMConfig) benchmark(runs int) error {
+	c.bench = true
+	original := c.Packages
+
+	// timings maps package and stage to the seconds of every run
+	timings := map[string]map[string][]float64{}
+	keys := []string{}
+	for run := 1; run <= runs; run++ {
+		fmt.Printf("benchmark run %d of %d\n", run, runs)
+		c.Packages = append([]Package{}, original...)
+		c.report.Packages = nil
+		if err := c.build(); err != nil {
+			return err
+		}
+		for _, r := range c.report.Packages {
+			key := fmt.Sprintf("%s (%s)", r.Name, r.Mode)
+			if timings[key] == nil {
+				timings[key] = map[string][]float64{}
+				keys = append(keys, key)
+			}
+			for stage, seconds := range r.Timings {
+				timings[key][stage] = append(timings[key][stage], seconds)
+			}
+			if run < runs {
+				for _, f := range append([]string{r.Artifact}, r.Signatures...) {
+					os.Remove(f)
+				}
+			}
+		}
+	}
+
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "\n%-40s %-14s %10s %10s %10s\n", "package", "stage", "min", "mean", "max")
+	for _, key := range keys {
+		for _, stage := range benchStages {
+			if values := timings[key][stage]; len(values) > 0 {
+				min, mean, max := durationStats(values)
+				fmt.Fprintf(b, "%-40s %-14s %9.2fs %9.2fs %9.2fs\n", key, stage, min, mean, max)
+			}
+		}
+	}
+
+	// the packages of the last run are turned into dir packages where possible, so their contents are known
+	fmt.Fprintf(b, "\n%-40s %-14s %10s %12s %12s %7s\n", "package", "compressor", "time", "size", "compressed", "ratio")
+	for i := range c.Packages {
+		p := &c.Packages[i]
+		if p.Source.Mode != "dir" {
+			continue
+		}
+		for _, compressor := range benchCompressors {
+			if _, err := exec.LookPath(compressor[0]); err != nil {
+				continue
+			}
+			elapsed, size, compressed, err := p.probeCompression(compressor)
+			if err != nil {
+				fmt.Fprintf(b, "%-40s %-14s failed: %s\n", p.Name, compressor[0], err)
+				continue
+			}
+			ratio := 0.0
+			if size > 0 {
+				ratio = float64(compressed) / float64(size) * 100
+			}
+			fmt.Fprintf(b, "%-40s %-14s %9.2fs %12d %12d %6.1f%%\n",
+				p.Name, strings.Join(compressor, " "), elapsed.Seconds(), size, compressed, ratio)
+		}
+	}
+	fmt.Print(b.String())
+	return nil
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cache keeps the built packages in the Actions cache, so later jobs restore them instead of rebuilding
+type Cache struct {
+	// Key distinguishes several builds of the same commit and configuration e.g. legs of a matrix *OPTIONAL*
+	Key string `yaml:"key"`
+}
+
+// name of the report stored alongside the artifacts in the cache
+const cachedReport = "action-package-cache.json"
+
+// version of the cache entries, entries written by other tools with the same key are not restored
+var cacheVersion = fmt.Sprintf("%x", sha256.Sum256([]byte("action-package|tar|gzip")))
+
+// method key returns the cache key of the packages built from the current commit and configuration
+func (c *Cache) key(config []byte) string {
+	sum := sha256.Sum256(config)
+	key := fmt.Sprintf("action-package-%s-%s", os.Getenv("GITHUB_SHA"), hex.EncodeToString(sum[:])[:16])
+	if c.Key != "" {
+		key += "-" + c.Key
+	}
+	return key
+}
+
+// cacheService talks to the cache service of GitHub Actions
+// the url and the token are only available to actions, not to run steps
+type cacheService struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// function newCacheService creates a client using ACTIONS_RESULTS_URL and ACTIONS_RUNTIME_TOKEN
+func newCacheService() (*cacheService, error) {
+	url := os.Getenv("ACTIONS_RESULTS_URL")
+	token := os.Getenv("ACTIONS_RUNTIME_TOKEN")
+	if url == "" || token == "" {
+		return nil, fmt.Errorf("ACTIONS_RESULTS_URL and ACTIONS_RUNTIME_TOKEN are not set, not running in GitHub Actions?")
+	}
+	return &cacheService{
+		url:    strings.TrimSuffix(url, "/") + "/twirp/github.actions.results.api.v1.CacheService/",
+		token:  token,
+		client: &http.Client{Timeout: 10 * time.Minute},
+	}, nil
+}
+
+// method call invokes a method of the cache service and decodes the json response into result
+func (s *cacheService) call(method string, body, result interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.url+method, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	contents, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("cache %s failed: %s %s", method, resp.Status, contents)
+	}
+	return json.Unmarshal(contents, result)
+}
+
+// method transfer sends a request to a signed url of the blob storage behind the cache
+func (s *cacheService) transfer(req *http.Request) (*http.Response, error) {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		contents, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("cache transfer failed: %s %s", resp.Status, contents)
+	}
+	return resp, nil
+}
+
+// method save uploads the file as cache entry key, existing entries can not be replaced
+func (s *cacheService) save(key, file string) error {
+	created := struct {
+		OK  bool   `json:"ok"`
+		URL string `json:"signed_upload_url"`
+	}{}
+	if err := s.call("CreateCacheEntry", map[string]string{"key": key, "version": cacheVersion}, &created); err != nil {
+		return err
+	}
+	if !created.OK {
+		return fmt.Errorf("the cache entry %s exists already or is being written", key)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPut, created.URL, f)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = info.Size()
+	req.Header.Set("x-ms-blob-type", "BlockBlob")
+	resp, err := s.transfer(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	finalized := struct {
+		OK bool `json:"ok"`
+	}{}
+	body := map[string]string{"key": key, "version": cacheVersion, "size_bytes": strconv.FormatInt(info.Size(), 10)}
+	if err := s.call("FinalizeCacheEntryUpload", body, &finalized); err != nil {
+		return err
+	}
+	if !finalized.OK {
+		return fmt.Errorf("the cache entry %s could not be finalized", key)
+	}
+	return nil
+}
+
+// method restore downloads the cache entry key to file, found is false if there is no such entry
+func (s *cacheService) restore(key, file string) (found bool, err error) {
+	entry := struct {
+		OK  bool   `json:"ok"`
+		URL string `json:"signed_download_url"`
+	}{}
+	body := map[string]interface{}{"key": key, "restore_keys": []string{}, "version": cacheVersion}
+	if err := s.call("GetCacheEntryDownloadURL", body, &entry); err != nil {
+		return false, err
+	}
+	if !entry.OK || entry.URL == "" {
+		return false, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, entry.URL, nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := s.transfer(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	f, err := os.Create(file)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	_, err = io.Copy(f, resp.Body)
+	return err == nil, err
+}
+
+// method saveCache stores the artifacts and signatures of all built packages along with the report
+// artifacts keep their paths, so they are restored to the same location in later jobs
+func (c *FPMConfig) saveCache(config []byte) error {
+	s, err := newCacheService()
+	if err != nil {
+		return err
+	}
+	dir, err := ioutil.TempDir("", "cache-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	report, err := json.Marshal(c.report.Packages)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, cachedReport), report, 0644); err != nil {
+		return err
+	}
+
+	files := []string{}
+	for _, r := range c.report.Packages {
+		if r.Artifact != "" {
+			files = append(files, r.Artifact)
+		}
+		files = append(files, r.Signatures...)
+	}
+	archive := filepath.Join(dir, "cache.tgz")
+	args := append([]string{"-czPf", archive, "-C", dir, cachedReport, "-C", "."}, files...)
+	if output, err := exec.Command("tar", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s\n%s", err, output)
+	}
+
+	key := c.Cache.key(config)
+	if err := s.save(key, archive); err != nil {
+		return err
+	}
+	fmt.Printf(tr("saved %d files to the cache as %s\n"), len(files), key)
+	return nil
+}
+
+// method restoreCache restores the artifacts and the report of a previous job
+// restored is false if the packages of this commit and configuration were not cached
+func (c *FPMConfig) restoreCache(config []byte) (restored bool, err error) {
+	s, err := newCacheService()
+	if err != nil {
+		return false, err
+	}
+	dir, err := ioutil.TempDir("", "cache-")
+	if err != nil {
+		return false, err
+	}
+	defer os.RemoveAll(dir)
+
+	key := c.Cache.key(config)
+	archive := filepath.Join(dir, "cache.tgz")
+	if found, err := s.restore(key, archive); err != nil || !found {
+		return false, err
+	}
+	if output, err := exec.Command("tar", "-xzPf", archive, "-C", dir, cachedReport).CombinedOutput(); err != nil {
+		return false, fmt.Errorf("%s\n%s", err, output)
+	}
+	if output, err := exec.Command("tar", "-xzPf", archive, "--exclude", cachedReport).CombinedOutput(); err != nil {
+		return false, fmt.Errorf("%s\n%s", err, output)
+	}
+
+	report, err := ioutil.ReadFile(filepath.Join(dir, cachedReport))
+	if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(report, &c.report.Packages); err != nil {
+		return false, err
+	}
+	fmt.Printf(tr("restored %d packages from the cache %s\n"), len(c.report.Packages), key)
+	return true, nil
+}
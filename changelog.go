@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// function bumpIteration returns the next iteration, packages without an iteration count as iteration 1
+func bumpIteration(iteration string) string {
+	n, err := strconv.Atoi(iteration)
+	if err != nil || n < 1 {
+		n = 1
+	}
+	return strconv.Itoa(n + 1)
+}
+
+// method fullVersion returns the version including epoch and iteration like it is shown by the package managers
+func (p *Package) fullVersion() string {
+	version := p.Target.Version
+	if p.Target.Epoch != "" {
+		version = p.Target.Epoch + ":" + version
+	}
+	if p.Target.Iteration != "" {
+		version += "-" + p.Target.Iteration
+	}
+	return version
+}
+
+// method changelogFile returns the changelog of the package, including the entry added by --append-changelog
+func (p *Package) changelogFile() string {
+	if p.changelog != "" {
+		return p.changelog
+	}
+	return p.Target.Changelog
+}
+
+// method changelogEntry formats message as a changelog entry of the target mode
+// only deb and rpm packages carry a changelog, other modes return an empty entry
+func (p *Package) changelogEntry(message string) string {
+	maintainer := p.Target.Maintainer
+	if maintainer == "" {
+		maintainer = "action-package <noreply@github.com>"
+	}
+	now := buildTime()
+
+	switch p.Target.Mode {
+	case "deb":
+		return fmt.Sprintf("%s (%s) unstable; urgency=high\n\n  * %s\n\n -- %s  %s\n",
+			p.Name, p.fullVersion(), message, maintainer, now.Format("Mon, 02 Jan 2006 15:04:05 -0700"))
+	case "rpm":
+		return fmt.Sprintf("* %s %s - %s\n- %s\n",
+			now.Format("Mon Jan 02 2006"), maintainer, p.fullVersion(), message)
+	}
+	return ""
+}
+
+// method appendChangelog prepares a hotfix rebuild of all packages
+//
+// the iteration of every package is bumped, so the rebuild replaces the published packages,
+// deb and rpm packages get a changelog entry with message on top of their changelog. no files of the repository are modified
+func (c *FPMConfig) appendChangelog(message string) error {
+	message = strings.TrimSpace(message)
+	if message == "" {
+		return nil
+	}
+	dir, err := ioutil.TempDir("", "changelog-")
+	if err != nil {
+		return err
+	}
+
+	for i := range c.Packages {
+		p := &c.Packages[i]
+		p.Target.Iteration = bumpIteration(p.Target.Iteration)
+
+		entry := p.changelogEntry(message)
+		if entry == "" {
+			continue
+		}
+		// the entry is added on top of the configured changelog
+		if p.Target.Changelog != "" {
+			existing, err := ioutil.ReadFile(p.Target.Changelog)
+			if err != nil {
+				return err
+			}
+			entry += "\n" + string(existing)
+		}
+		p.changelog = filepath.Join(dir, fmt.Sprintf("%s_%s.changelog", p.Name, p.Target.Mode))
+		if err := ioutil.WriteFile(p.changelog, []byte(entry), 0644); err != nil {
+			return err
+		}
+		fmt.Printf(tr("added changelog entry to %s %s\n"), p.Name, p.fullVersion())
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// compression levels accepted by the compressors that can run multithreaded
+var compressionLevels = map[string][2]int{
+	"xz":   {0, 9},
+	"zstd": {1, 19},
+}
+
+// method checkCompression validates the level and thread count of the compression
+func (p *Package) checkCompression() error {
+	if p.Target.CompressionLevel == nil && p.Target.CompressionThreads == nil {
+		return nil
+	}
+	levels, ok := compressionLevels[p.Target.Compression]
+	if !ok {
+		return ConfigError{
+			packageEntry: p.Name,
+			field:        "target.compression_level|compression_threads",
+			message:      "compression level and threads are only available for compression xz and zstd",
+		}
+	}
+	if l := p.Target.CompressionLevel; l != nil && (*l < levels[0] || *l > levels[1]) {
+		return ConfigError{
+			packageEntry: p.Name,
+			field:        "target.compression_level",
+			message:      fmt.Sprintf(tr("the level of %s must be between %d and %d"), p.Target.Compression, levels[0], levels[1]),
+		}
+	}
+	if t := p.Target.CompressionThreads; t != nil && *t < 0 {
+		return ConfigError{
+			packageEntry: p.Name,
+			field:        "target.compression_threads",
+			message:      "the number of threads must not be negative, 0 uses all cores",
+		}
+	}
+	return nil
+}
+
+// method compressionEnv returns the environment passing level and threads to the compressor run by fpm
+// fpm calls xz and zstd through tar, both read their options from the environment
+func (p *Package) compressionEnv() []string {
+	level, threads := "", ""
+	if p.Target.CompressionLevel != nil {
+		level = strconv.Itoa(*p.Target.CompressionLevel)
+	}
+	if p.Target.CompressionThreads != nil {
+		threads = strconv.Itoa(*p.Target.CompressionThreads)
+	}
+
+	env := []string{}
+	switch p.Target.Compression {
+	case "xz":
+		options := os.Getenv("XZ_OPT")
+		if level != "" {
+			options += " -" + level
+		}
+		if threads != "" {
+			options += " -T" + threads
+		}
+		if options != os.Getenv("XZ_OPT") {
+			env = append(env, "XZ_OPT="+options)
+		}
+	case "zstd":
+		if level != "" {
+			env = append(env, "ZSTD_CLEVEL="+level)
+		}
+		if threads != "" {
+			env = append(env, "ZSTD_NBTHREADS="+threads)
+		}
+	}
+	return env
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// configKeyEnv names the environment variable pointing to the public key packages.yml has to be signed with
+// platform teams set it on protected runners, so a branch can not opt out of the verification
+const configKeyEnv = "PACKAGE_CONFIG_KEY"
+
+// function configKeyConfigured decides whether configurations have to be signed, by the runner or the workflow
+func configKeyConfigured(key string) bool {
+	return key != "" || os.Getenv(configKeyEnv) != ""
+}
+
+// function configKey returns the armored or binary public key given as file path or inline
+// the key of the runner takes precedence over the key of the workflow
+func configKey(key string) ([]byte, error) {
+	if path := os.Getenv(configKeyEnv); path != "" {
+		key = path
+	}
+	if strings.HasPrefix(strings.TrimSpace(key), "-----BEGIN PGP") {
+		return []byte(key), nil
+	}
+	return ioutil.ReadFile(key)
+}
+
+// function verifyConfig checks the detached OpenPGP signature of a configuration file with gpgv
+// without a signature path packages.yml.sig and packages.yml.asc are tried
+func verifyConfig(config, key, signature string) error {
+	publicKey, err := configKey(key)
+	if err != nil {
+		return fmt.Errorf("could not read the public key: %s", err)
+	}
+	return verifyConfigWith(config, publicKey, signature)
+}
+
+// function verifyConfigWith checks the signature of a configuration file with a public key read already
+func verifyConfigWith(config string, publicKey []byte, signature string) error {
+	publicKey, err := dearmor(publicKey)
+	if err != nil {
+		return fmt.Errorf("could not read the public key: %s", err)
+	}
+
+	if signature == "" {
+		for _, candidate := range []string{config + ".sig", config + ".asc"} {
+			if _, err := os.Stat(candidate); err == nil {
+				signature = candidate
+				break
+			}
+		}
+		if signature == "" {
+			return fmt.Errorf("%s is not signed, neither %s.sig nor %s.asc exist", config, config, config)
+		}
+	}
+
+	keyring, err := ioutil.TempFile("", "config-key-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(keyring.Name())
+	if _, err := keyring.Write(publicKey); err != nil {
+		return err
+	}
+	keyring.Close()
+
+	cmd := exec.Command("gpgv", "--keyring", keyring.Name(), signature, config)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("signature %s of %s is invalid: %s\n%s", signature, config, err, output)
+	}
+	fmt.Printf(tr("verified the signature %s of %s\n"), signature, config)
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// method declaresConflict decides whether the package declares a conflict with or replaces the named package
+// entries of conflicts and replaces may carry a version constraint like "example (<< 2.0)"
+func (p *Package) declaresConflict(name string) bool {
+	for _, c := range append(append([]string{}, p.Target.Conflicts...), p.Target.Replaces...) {
+		if fields := strings.Fields(c); len(fields) > 0 && fields[0] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// method checkConflicts fails if two packages install the same path without declaring a conflict or replacement
+// only packages whose files are known before building are compared, so composite packages and packages
+// changed by post_stage hooks are skipped. entries of the same package expanded from several target modes
+// never conflict with each other
+func (c *FPMConfig) checkConflicts() error {
+	// owners maps every installed path to the packages installing it
+	owners := map[string][]*Package{}
+	for i := range c.Packages {
+		p := &c.Packages[i]
+		if (i > 0 && c.Packages[i-1].Name == p.Name) || len(p.Sources) > 0 || len(p.Hooks.PostStage) > 0 {
+			continue
+		}
+		files, err := p.contents()
+		if err != nil {
+			return fmt.Errorf("could not list the files of package %s: %s", p.Name, err)
+		}
+		for _, f := range files {
+			owners[f.Target] = append(owners[f.Target], p)
+		}
+	}
+
+	// overlaps lists the shared files of every pair of packages
+	overlaps := map[string][]string{}
+	for path, packages := range owners {
+		for i, a := range packages {
+			for _, b := range packages[i+1:] {
+				if a.Name == b.Name || a.declaresConflict(b.Name) || b.declaresConflict(a.Name) {
+					continue
+				}
+				pair := fmt.Sprintf("%s and %s", a.Name, b.Name)
+				overlaps[pair] = append(overlaps[pair], path)
+			}
+		}
+	}
+	if len(overlaps) == 0 {
+		return nil
+	}
+
+	pairs := []string{}
+	for pair := range overlaps {
+		pairs = append(pairs, pair)
+	}
+	sort.Strings(pairs)
+	b := &strings.Builder{}
+	b.WriteString("packages install the same files without declaring conflicts:\n")
+	for _, pair := range pairs {
+		files := overlaps[pair]
+		sort.Strings(files)
+		fmt.Fprintf(b, "%s both install:\n", pair)
+		for _, f := range files {
+			fmt.Fprintf(b, "  %s\n", f)
+		}
+	}
+	return fmt.Errorf("%s", b.String())
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// maximal number of violations listed when content rules fail
+const maxListedViolations = 20
+
+// ContentRules restrict what a package may contain, they are checked against the staged files
+// rule sets are defined by name in packages.yml or in a yaml file shared across configurations
+type ContentRules struct {
+	// Allow lists the only installed paths or patterns a package may contain *OPTIONAL*
+	// a directory allows everything below it e.g. "/opt/example"
+	Allow []string `yaml:"allow"`
+
+	// Deny lists installed paths or patterns a package must not contain e.g. "/etc/sudoers.d" *OPTIONAL*
+	Deny []string `yaml:"deny"`
+
+	// MaxFiles is the maximal number of files of a package *OPTIONAL*
+	MaxFiles int `yaml:"max_files"`
+
+	// ForbiddenTypes are file extensions like ".pyc" or the kinds "core" (core dumps) and "elf" (binaries) *OPTIONAL*
+	ForbiddenTypes []string `yaml:"forbidden_types"`
+}
+
+// method contentRules returns the rule set of a name, names ending in .yml or .yaml are read from that file
+func (c *FPMConfig) contentRules(name string) (*ContentRules, error) {
+	if ext := filepath.Ext(name); ext == ".yml" || ext == ".yaml" {
+		contents, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		r := &ContentRules{}
+		if err := yaml.UnmarshalStrict(contents, r); err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+		return r, nil
+	}
+	r, ok := c.ContentRules[name]
+	if !ok {
+		return nil, fmt.Errorf("there is no rule set %s in content_rules", name)
+	}
+	return &r, nil
+}
+
+// method check validates a rule set
+func (r *ContentRules) check(name string) error {
+	if r.MaxFiles < 0 {
+		return fmt.Errorf("max_files of rule set %s must not be negative", name)
+	}
+	for _, t := range r.ForbiddenTypes {
+		if !strings.HasPrefix(t, ".") && t != "core" && t != "elf" {
+			return fmt.Errorf("forbidden type %s of rule set %s is neither an extension like .pyc nor core|elf", t, name)
+		}
+	}
+	return nil
+}
+
+// method checkContentRules validates the rule sets applied to a package
+func (c *FPMConfig) checkContentRules(p *Package) error {
+	for _, name := range p.ContentRules {
+		r, err := c.contentRules(name)
+		if err == nil {
+			err = r.check(name)
+		}
+		if err != nil {
+			return ConfigError{
+				packageEntry: p.Name,
+				field:        "content_rules",
+				message:      err.Error(),
+			}
+		}
+	}
+	return nil
+}
+
+// function elfType returns the type of an ELF file, ok is false for other files
+func elfType(path string) (elf.Type, bool) {
+	f, err := elf.Open(path)
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+	return f.Type, true
+}
+
+// method violations lists the files of a package breaking the rules
+func (r *ContentRules) violations(files []ContentFile) []string {
+	found := []string{}
+	if r.MaxFiles > 0 && len(files) > r.MaxFiles {
+		found = append(found, fmt.Sprintf("%d files exceed the maximum of %d", len(files), r.MaxFiles))
+	}
+
+	matches := func(patterns []string, target string) bool {
+		for _, pattern := range patterns {
+			if matchesPath(strings.TrimPrefix(pattern, "/"), strings.TrimPrefix(target, "/")) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, f := range files {
+		if len(r.Allow) > 0 && !matches(r.Allow, f.Target) {
+			found = append(found, fmt.Sprintf("%s is not allowed", f.Target))
+		}
+		if matches(r.Deny, f.Target) {
+			found = append(found, fmt.Sprintf("%s is denied", f.Target))
+		}
+		for _, t := range r.ForbiddenTypes {
+			switch {
+			case strings.HasPrefix(t, "."):
+				if strings.HasSuffix(f.Target, t) {
+					found = append(found, fmt.Sprintf("%s is a forbidden %s file", f.Target, t))
+				}
+			case f.Info.Mode().IsRegular():
+				if kind, ok := elfType(f.Source); ok && (t == "elf" || kind == elf.ET_CORE) {
+					found = append(found, fmt.Sprintf("%s is a forbidden %s file", f.Target, t))
+				}
+			}
+		}
+	}
+	return found
+}
+
+// method applyContentRules checks the staged files against all rule sets of the package
+func (c *FPMConfig) applyContentRules(p *Package) error {
+	files, err := p.contents()
+	if err != nil {
+		return err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Target < files[j].Target })
+
+	listed := &bytes.Buffer{}
+	count := 0
+	for _, name := range p.ContentRules {
+		r, err := c.contentRules(name)
+		if err != nil {
+			return err
+		}
+		for _, v := range r.violations(files) {
+			if count < maxListedViolations {
+				fmt.Fprintf(listed, "  %s (%s)\n", v, name)
+			}
+			count++
+		}
+	}
+	if count == 0 {
+		return nil
+	}
+	if count > maxListedViolations {
+		fmt.Fprintf(listed, "  and %d more\n", count-maxListedViolations)
+	}
+	return fmt.Errorf("package %s breaks its content rules:\n%s", p.Name, listed)
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ContentFile is a single file that will end up in a package
+type ContentFile struct {
+	// Source is the path of the file on disk
+	Source string
+
+	// Target is the absolute path the file will be installed to
+	Target string
+
+	// Info is the result of an lstat on Source
+	Info os.FileInfo
+}
+
+// function excluded decides whether a path relative to the source root matches one of the exclude patterns
+// patterns may match the whole path, the base name or a parent directory (e.g. ".git/")
+func excluded(path string, patterns []string) bool {
+	for _, e := range patterns {
+		e = strings.TrimSuffix(e, "/")
+		if ok, _ := filepath.Match(e, path); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(e, filepath.Base(path)); ok {
+			return true
+		}
+		if strings.HasPrefix(path, e+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// function splitPath separates a package path argument of the form "src=dst" into its parts
+// paths without a mapping are installed relative to the package root
+func splitPath(a string) (string, string) {
+	if i := strings.Index(a, "="); i >= 0 {
+		return a[:i], a[i+1:]
+	}
+	return a, a
+}
+
+// method contents lists all files the package will contain
+//
+// only source mode "dir" can be inspected before fpm runs, other modes return an empty list
+// the result is sorted by target path
+func (p *Package) contents() ([]ContentFile, error) {
+	files := []ContentFile{}
+	if p.Source.Mode != "dir" {
+		return files, nil
+	}
+
+	root := p.Source.Chdir
+	if root == "" {
+		root = "."
+	}
+
+	paths := p.Paths
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	for _, a := range paths {
+		src, dst := splitPath(a)
+
+		// absolute paths are not relative to the working directory
+		base := root
+		if filepath.IsAbs(src) {
+			base = "/"
+		}
+
+		err := filepath.Walk(filepath.Join(base, src), func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			rel, err := filepath.Rel(base, path)
+			if err != nil {
+				return err
+			}
+			if rel != "." && excluded(rel, p.excludes()) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if info.IsDir() {
+				return nil
+			}
+
+			inner, err := filepath.Rel(filepath.Join(base, src), path)
+			if err != nil {
+				return err
+			}
+			files = append(files, ContentFile{
+				Source: path,
+				Target: filepath.Join("/", p.Target.Prefix, dst, inner),
+				Info:   info,
+			})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Target < files[j].Target })
+	return files, nil
+}
+
+// method pathArgument creates a "src=dst" path argument for a file outside of the source tree
+// fpm resolves sources relative to chdir, so the source path is made relative to it
+func (p *Package) pathArgument(src, dst string) (string, error) {
+	if p.Source.Chdir != "" {
+		root, err := filepath.Abs(p.Source.Chdir)
+		if err != nil {
+			return "", err
+		}
+		if src, err = filepath.Rel(root, src); err != nil {
+			return "", err
+		}
+	}
+	return src + "=" + dst, nil
+}
+
+// function readPathList reads a newline delimited list of paths
+// empty lines and comments starting with # are skipped
+func readPathList(r io.Reader) ([]string, error) {
+	paths := []string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		paths = append(paths, line)
+	}
+	return paths, scanner.Err()
+}
+
+// method excludes returns the exclude patterns of the package including those of the exclude file
+func (p *Package) excludes() []string {
+	return append(append([]string{}, p.Source.Excludes...), p.fileExcludes...)
+}
+
+// method readExcludeFiles reads the patterns of the exclude files, fpm reads the files itself
+// the patterns are needed to inspect the contents of the package before fpm runs
+func (c *FPMConfig) readExcludeFiles() error {
+	for i := range c.Packages {
+		p := &c.Packages[i]
+		if p.Source.ExcludeFile == "" {
+			continue
+		}
+		f, err := os.Open(p.Source.ExcludeFile)
+		if err != nil {
+			return fmt.Errorf("could not read excludes of package %s: %s", p.Name, err)
+		}
+		p.fileExcludes, err = readPathList(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("could not read excludes of package %s: %s", p.Name, err)
+		}
+	}
+	return nil
+}
+
+// method readPathLists appends the paths listed in the paths_from files to the paths of each package
+// stdin can only be read once, its list is shared by all packages reading from "-"
+func (c *FPMConfig) readPathLists() error {
+	var stdin []string
+	for i := range c.Packages {
+		p := &c.Packages[i]
+		if p.PathsFrom == "" {
+			continue
+		}
+
+		var paths []string
+		if p.PathsFrom == "-" {
+			if stdin == nil {
+				list, err := readPathList(os.Stdin)
+				if err != nil {
+					return fmt.Errorf("could not read paths of package %s from stdin: %s", p.Name, err)
+				}
+				stdin = list
+			}
+			paths = stdin
+		} else {
+			f, err := os.Open(p.PathsFrom)
+			if err != nil {
+				return fmt.Errorf("could not read paths of package %s: %s", p.Name, err)
+			}
+			paths, err = readPathList(f)
+			f.Close()
+			if err != nil {
+				return fmt.Errorf("could not read paths of package %s: %s", p.Name, err)
+			}
+		}
+		p.Paths = append(p.Paths, paths...)
+	}
+	return nil
+}
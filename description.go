@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// default maximal length of descriptions read from a README
+const defaultDescriptionLength = 1000
+
+// markdown that is removed or replaced by its text when a README becomes a description
+var (
+	markdownImage     = regexp.MustCompile(`!\[[^\]]*\](\([^)]*\)|\[[^\]]*\])`)
+	markdownLink      = regexp.MustCompile(`\[([^\]]*)\](\([^)]*\)|\[[^\]]*\])`)
+	markdownHTML      = regexp.MustCompile(`<[^>]+>`)
+	markdownEmphasis  = regexp.MustCompile("\\*\\*|__|`")
+	markdownHeading   = regexp.MustCompile(`^#+\s*(.*?)\s*#*$`)
+	markdownListStart = regexp.MustCompile(`^([-*+]|[0-9]+\.)\s`)
+)
+
+// ReadmeDescription reads the description of a package from a markdown file like README.md
+type ReadmeDescription struct {
+	// File is the markdown file relative to the working directory
+	File string `yaml:"file"`
+
+	// Section is the heading of the section to use e.g. "Summary", defaults to the first paragraph *OPTIONAL*
+	Section string `yaml:"section"`
+
+	// MaxLength is the maximal number of characters, longer descriptions are cut at a word *OPTIONAL*
+	MaxLength int `yaml:"max_length"`
+}
+
+// function sanitizeMarkdown turns a line of markdown into plain text
+// images and html are removed, links are replaced by their text
+func sanitizeMarkdown(line string) string {
+	line = markdownImage.ReplaceAllString(line, "")
+	line = markdownLink.ReplaceAllString(line, "$1")
+	line = markdownHTML.ReplaceAllString(line, "")
+	line = markdownEmphasis.ReplaceAllString(line, "")
+	return strings.Join(strings.Fields(line), " ")
+}
+
+// function truncateDescription cuts a description longer than max characters at the last word that fits
+func truncateDescription(description string, max int) string {
+	runes := []rune(description)
+	if len(runes) <= max {
+		return description
+	}
+	cut := string(runes[:max-3])
+	if i := strings.LastIndexAny(cut, " \n"); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " \n.,;:") + "..."
+}
+
+// method read extracts the description from the markdown file
+//
+// without a section the first paragraph of text is used, headings, badges, code blocks and html are skipped.
+// a section contributes all its paragraphs up to the next heading. paragraphs are joined into single lines
+func (d *ReadmeDescription) read() (string, error) {
+	f, err := os.Open(d.File)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	paragraphs := []string{}
+	current := []string{}
+	inSection := d.Section == ""
+	fenced := false
+	done := false
+	flush := func() {
+		if text := strings.Join(current, " "); text != "" {
+			paragraphs = append(paragraphs, text)
+			done = d.Section == ""
+		}
+		current = nil
+	}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() && !done {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~") {
+			fenced = !fenced
+			flush()
+			continue
+		}
+		if fenced {
+			continue
+		}
+		heading := markdownHeading.FindStringSubmatch(line)
+		if heading == nil && len(line) >= 2 && len(current) > 0 && (strings.Trim(line, "=") == "" || strings.Trim(line, "-") == "") {
+			// the lines above an underline of = or - are a heading as well
+			heading = []string{line, strings.Join(current, " ")}
+			current = nil
+		}
+		if heading != nil {
+			flush()
+			if d.Section != "" {
+				if inSection && len(paragraphs) > 0 {
+					break
+				}
+				inSection = strings.EqualFold(sanitizeMarkdown(heading[1]), d.Section)
+			}
+			continue
+		}
+		if !inSection {
+			continue
+		}
+		// tables are skipped, list items start a new paragraph
+		if line == "" || strings.HasPrefix(line, "|") {
+			flush()
+			continue
+		}
+		if markdownListStart.MatchString(line) {
+			flush()
+		}
+		line = strings.TrimLeft(line, "> ")
+		if text := sanitizeMarkdown(line); text != "" {
+			current = append(current, text)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	flush()
+
+	if len(paragraphs) == 0 {
+		if d.Section != "" {
+			return "", fmt.Errorf("%s has no section %s with text", d.File, d.Section)
+		}
+		return "", fmt.Errorf("%s contains no paragraph of text", d.File)
+	}
+	max := d.MaxLength
+	if max == 0 {
+		max = defaultDescriptionLength
+	}
+	return truncateDescription(strings.Join(paragraphs, "\n"), max), nil
+}
+
+// method applyReadmeDescriptions sets the description of packages reading it from a README
+func (c *FPMConfig) applyReadmeDescriptions() error {
+	for i := range c.Packages {
+		p := &c.Packages[i]
+		d := p.Target.DescriptionFrom
+		if d == nil {
+			continue
+		}
+
+		message := ""
+		switch {
+		case p.Target.Description != "":
+			message = "description and description_from can not be combined"
+		case d.File == "":
+			message = "the markdown file to read the description from is missing"
+		case d.MaxLength != 0 && d.MaxLength < 20:
+			message = "max_length has to be at least 20 characters"
+		}
+		if message == "" {
+			description, err := d.read()
+			if err != nil {
+				message = err.Error()
+			}
+			p.Target.Description = description
+		}
+		if message != "" {
+			return ConfigError{
+				packageEntry: p.Name,
+				field:        "target.description_from",
+				message:      message,
+			}
+		}
+	}
+	return nil
+}
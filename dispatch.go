@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Dispatch maps inputs of a workflow_dispatch event onto the configuration
+// each field names the workflow input, inputs that are empty or missing change nothing
+type Dispatch struct {
+	// Version names the input that overrides the version of all packages
+	Version string `yaml:"version"`
+
+	// Channel names the input that overrides the distribution of all publish targets
+	Channel string `yaml:"channel"`
+
+	// Packages names the input that holds a comma or space separated list of packages to build
+	// all other packages are skipped
+	Packages string `yaml:"packages"`
+}
+
+// function dispatchInput returns the value of a workflow_dispatch input
+func dispatchInput(name string) string {
+	if name == "" {
+		return ""
+	}
+	return strings.TrimSpace(eventField("inputs." + name))
+}
+
+// method applyDispatch applies the workflow_dispatch inputs to the configuration
+func (c *FPMConfig) applyDispatch() error {
+	d := c.Dispatch
+	if d == nil {
+		return nil
+	}
+	return c.override(dispatchInput(d.Version), dispatchInput(d.Channel), dispatchInput(d.Packages),
+		"dispatch.packages", fmt.Sprintf("input %s", d.Packages))
+}
+
+// method override sets the version of all packages, the distribution of all publish targets
+// and selects a subset of packages, empty values change nothing
+// field and origin describe where the subset came from for error messages
+func (c *FPMConfig) override(version, channel, subset, field, origin string) error {
+	if version != "" {
+		for i := range c.Packages {
+			c.Packages[i].Target.Version = version
+		}
+	}
+
+	if channel != "" {
+		for i := range c.Publish {
+			c.Publish[i].Distribution = channel
+		}
+	}
+
+	if subset != "" {
+		names := strings.FieldsFunc(subset, func(r rune) bool { return r == ',' || r == ' ' })
+
+		selected := []Package{}
+		for _, p := range c.Packages {
+			if contains(names, p.Name) {
+				selected = append(selected, p)
+			}
+		}
+		for _, n := range names {
+			found := false
+			for _, p := range selected {
+				found = found || p.Name == n
+			}
+			if !found {
+				return ConfigError{
+					field:   field,
+					message: fmt.Sprintf(tr("%s selects unknown package %s"), origin, n),
+				}
+			}
+		}
+		c.Packages = selected
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// exports caches the exported filesystems of source mode docker by image
+// packages with several target modes export the image only once
+var exports = map[string]string{}
+
+// paths of the exported filesystem created by docker itself, they are never packaged
+var dockerExcludes = []string{".dockerenv", "dev", "proc", "sys"}
+
+// function docker runs a docker command and returns its trimmed output
+func docker(args ...string) (string, error) {
+	output, err := exec.Command("docker", args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("docker %s failed: %s\n%s", args[0], err, output)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// function export extracts the filesystem of an image into a temporary directory
+// the image is pulled if it is not available locally, ownership and modes are kept
+func export(image string) (string, error) {
+	if dir, ok := exports[image]; ok {
+		return dir, nil
+	}
+
+	dir, err := ioutil.TempDir("", "docker-")
+	if err != nil {
+		return "", err
+	}
+	// the container is never started, the command only satisfies images without one
+	container, err := docker("create", image, "true")
+	if err != nil {
+		return "", err
+	}
+	defer docker("rm", container)
+
+	archive := filepath.Join(dir, "filesystem.tar")
+	root := filepath.Join(dir, "filesystem")
+	if _, err := docker("export", "--output", archive, container); err != nil {
+		return "", err
+	}
+	if err := os.Mkdir(root, 0755); err != nil {
+		return "", err
+	}
+	if output, err := exec.Command("tar", "--numeric-owner", "-xf", archive, "-C", root).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("%s\n%s", err, output)
+	}
+	exports[image] = root
+	return root, nil
+}
+
+// method exportImage exports the image of source mode docker and turns the package into a dir package
+// paths may be absolute paths inside the image, they are installed to the same path by default
+func (p *Package) exportImage() error {
+	fmt.Printf(tr("exporting %s...\n"), p.Source.Image)
+	dir, err := export(p.Source.Image)
+	if err != nil {
+		return err
+	}
+	id, err := docker("image", "inspect", "--format", "{{.Id}}", p.Source.Image)
+	if err != nil {
+		return err
+	}
+	p.remotes = append(p.remotes, remoteSource{"docker " + p.Source.Image, id})
+
+	p.Source.Mode = "dir"
+	p.Source.Chdir = dir
+	p.Source.Excludes = append(append([]string{}, p.Source.Excludes...), dockerExcludes...)
+	if len(p.Paths) == 0 {
+		p.Paths = []string{"."}
+	}
+	paths := make([]string, len(p.Paths))
+	for i, path := range p.Paths {
+		src := strings.SplitN(path, "=", 2)[0]
+		switch {
+		case !filepath.IsAbs(src):
+			paths[i] = path
+		case src == path:
+			paths[i] = strings.TrimPrefix(src, "/") + "=" + src
+		default:
+			paths[i] = strings.TrimPrefix(path, "/")
+		}
+	}
+	p.Paths = paths
+	return nil
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// minimal free disk space in bytes before the doctor warns
+const minFreeSpace = 1 << 30
+
+// diagnosis is the result of a single check of the doctor command
+type diagnosis struct {
+	// status is "ok", "warn" or "fail"
+	status  string
+	subject string
+	message string
+}
+
+// function tool checks whether an executable is available and returns the first line of its version output
+func tool(name string, versionArgs ...string) (string, bool) {
+	if _, err := exec.LookPath(name); err != nil {
+		return "", false
+	}
+	output, err := exec.Command(name, versionArgs...).CombinedOutput()
+	if err != nil {
+		return "unknown version", true
+	}
+	return strings.SplitN(strings.TrimSpace(string(output)), "\n", 2)[0], true
+}
+
+// function checkTool diagnoses an executable, missing tools fail if they are required and warn otherwise
+func checkTool(required bool, hint, name string, versionArgs ...string) diagnosis {
+	if version, ok := tool(name, versionArgs...); ok {
+		return diagnosis{"ok", name, version}
+	}
+	if required {
+		return diagnosis{"fail", name, "not found, " + hint}
+	}
+	return diagnosis{"warn", name, "not found, " + hint}
+}
+
+// function checkEnv diagnoses a required environment variable
+func checkEnv(name, reason string) diagnosis {
+	if os.Getenv(name) == "" {
+		return diagnosis{"fail", name, "is not set but required " + reason}
+	}
+	return diagnosis{"ok", name, "is set"}
+}
+
+// function checkDiskSpace diagnoses the free space of the working directory
+func checkDiskSpace() diagnosis {
+	stat := syscall.Statfs_t{}
+	if err := syscall.Statfs(".", &stat); err != nil {
+		return diagnosis{"warn", "disk space", err.Error()}
+	}
+	free := stat.Bavail * uint64(stat.Bsize)
+	message := fmt.Sprintf("%d MiB free", free>>20)
+	if free < minFreeSpace {
+		return diagnosis{"warn", "disk space", message + ", large packages may fail to build"}
+	}
+	return diagnosis{"ok", "disk space", message}
+}
+
+// function checkReachable diagnoses whether a server answers http requests at all
+func checkReachable(subject, url string) diagnosis {
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return diagnosis{"fail", subject, fmt.Sprintf("%s is not reachable: %s", url, err)}
+	}
+	resp.Body.Close()
+	return diagnosis{"ok", subject, fmt.Sprintf("%s answered with %s", url, resp.Status)}
+}
+
+// method doctor checks the environment for everything the configuration needs
+func (c *FPMConfig) doctor(configErr error) []diagnosis {
+	results := []diagnosis{}
+
+	if configErr != nil {
+		results = append(results, diagnosis{"fail", "packages.yml", strings.TrimSpace(configErr.Error())})
+	} else {
+		results = append(results, diagnosis{"ok", "packages.yml", fmt.Sprintf("%d packages configured", len(c.Packages))})
+	}
+
+	results = append(results, checkTool(true, "install it with gem install fpm", "fpm", "--version"))
+
+	// tools needed by the configured target modes
+	modes := map[string]bool{}
+	for _, p := range c.Packages {
+		modes[p.Target.Mode] = true
+	}
+	if modes["rpm"] {
+		results = append(results, checkTool(true, "install rpm to build rpm packages", "rpmbuild", "--version"))
+	}
+	if modes["zip"] {
+		results = append(results, checkTool(true, "install zip to build zip archives", "zip", "-v"))
+	}
+	if modes["osxpkg"] {
+		results = append(results, checkTool(true, "osxpkg packages can only be built on a macOS runner", "pkgbuild", "--help"))
+	}
+
+	// tools needed by the configured source modes
+	sources := map[string]bool{}
+	for _, p := range c.Packages {
+		switch {
+		case p.Source.Mode == "pleaserun" && !sources["pleaserun"]:
+			results = append(results, checkTool(true, "install it with gem install pleaserun", "pleaserun", "--version"))
+		case p.Source.Mode == "virtualenv" && !sources["virtualenv"]:
+			results = append(results, checkTool(true, "install it with pip install virtualenv", "virtualenv", "--version"))
+		case p.Source.Mode == "go" && !sources["go"]:
+			results = append(results, checkTool(true, "it is needed for source mode go", "go", "version"))
+		case p.Source.Mode == "docker" && !sources["docker"]:
+			results = append(results, checkTool(true, "it is needed for source mode docker", "docker", "--version"))
+		case p.Source.Mode == "rpm" && !sources["rpm"]:
+			results = append(results, checkTool(true, "install rpm to convert rpm packages", "rpm2cpio", "--help"))
+		case p.Source.Mode == "python" && !sources["python:"+p.Source.Python]:
+			python := p.Source.Python
+			if python == "" {
+				python = "python"
+			}
+			results = append(results, checkTool(true, "it is needed for source mode python", python, "--version"))
+			sources["python:"+p.Source.Python] = true
+		}
+		sources[p.Source.Mode] = true
+	}
+
+	// tools needed by optional features
+	if c.Scan != nil {
+		results = append(results, checkTool(true, "it is configured as scanner", c.Scan.Scanner, "version"))
+		if modes["deb"] {
+			results = append(results, checkTool(true, "it is needed to extract deb packages for the scan", "dpkg-deb", "--version"))
+		}
+	}
+	results = append(results, checkTool(false, "it is needed to sign packages", "gpg", "--version"))
+
+	// environment needed by release notes
+	if c.ReleaseNotes != nil {
+		if contains(c.ReleaseNotes.Attach, "release") {
+			results = append(results, checkEnv("GITHUB_TOKEN", "to attach release notes to a release"))
+		}
+		if contains(c.ReleaseNotes.Attach, "summary") {
+			results = append(results, checkEnv("GITHUB_STEP_SUMMARY", "to attach release notes to the job summary"))
+		}
+	}
+
+	// publish targets need to be reachable
+	for i := range c.Publish {
+		t := &c.Publish[i]
+		switch t.Type {
+		case "aptly":
+			results = append(results, checkReachable(t.name(), strings.TrimSuffix(t.URL, "/")+"/api/version"))
+		case "packagecloud":
+			base := t.URL
+			if base == "" {
+				base = "https://packagecloud.io"
+			}
+			results = append(results, checkReachable(t.name(), base))
+		case "s3":
+			results = append(results, checkTool(true, "it is needed to publish to s3", "aws", "--version"))
+		}
+	}
+
+	results = append(results, checkDiskSpace())
+	return results
+}
+
+// function printDiagnoses prints the doctor results and returns whether all required checks passed
+func printDiagnoses(results []diagnosis) bool {
+	healthy := true
+	for _, d := range results {
+		fmt.Printf("[%-4s] %s: %s\n", d.status, d.subject, d.message)
+		if d.status == "fail" {
+			healthy = false
+		}
+	}
+	if healthy {
+		fmt.Printf("\nthe environment is ready to build\n")
+	} else {
+		fmt.Printf("\nfix the failed checks above before building\n")
+	}
+	return healthy
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+)
+
+// Drift warns when a package grows considerably compared to the previous release
+// accidental additions like build caches or debug symbols show up as sudden growth
+type Drift struct {
+	// Previous is a directory containing the manifests of the previous release *REQUIRED*
+	// e.g. restored from the actions cache or downloaded from the last release
+	Previous string `yaml:"previous"`
+
+	// MaxSizeIncrease is the growth of the installed size in percent that is accepted, defaults to 20
+	MaxSizeIncrease float64 `yaml:"max_size_increase"`
+
+	// MaxFilesIncrease is the growth of the number of files in percent that is accepted, defaults to 20
+	MaxFilesIncrease float64 `yaml:"max_files_increase"`
+
+	// Fail fails the build instead of warning *OPTIONAL*
+	Fail bool `yaml:"fail"`
+}
+
+// method check validates the drift configuration
+func (d *Drift) check() error {
+	if d.Previous == "" {
+		return ConfigError{
+			field:   "drift.previous",
+			message: "a directory containing the manifests of the previous release is required",
+		}
+	}
+	if d.MaxSizeIncrease < 0 || d.MaxFilesIncrease < 0 {
+		return ConfigError{
+			field:   "drift.max_size_increase|max_files_increase",
+			message: "the accepted increase must not be negative",
+		}
+	}
+	return nil
+}
+
+// function growth returns the increase from previous to current in percent
+func growth(previous, current int64) float64 {
+	if previous == 0 {
+		return 0
+	}
+	return float64(current-previous) / float64(previous) * 100
+}
+
+// function limit returns the configured threshold or the default
+func limit(threshold float64) float64 {
+	if threshold == 0 {
+		return 20
+	}
+	return threshold
+}
+
+// method run compares the package with its manifest of the previous release
+// findings are recorded as warnings and annotated, with Fail set an error is returned instead
+func (d *Drift) run(p *Package, r *PackageReport) error {
+	previous, ok, err := readManifest(d.Previous, p.Name)
+	if err != nil || !ok {
+		return err
+	}
+	current, err := p.manifest()
+	if err != nil {
+		return err
+	}
+
+	size := func(m Manifest) (total int64) {
+		for _, f := range m.Files {
+			total += f.Size
+		}
+		return total
+	}
+
+	findings := []string{}
+	if g := growth(size(previous), size(current)); g > limit(d.MaxSizeIncrease) {
+		findings = append(findings, fmt.Sprintf("installed size of %s grew by %.0f%% from %d to %d bytes since %s",
+			p.Name, g, size(previous), size(current), previous.Version))
+	}
+	if g := growth(int64(len(previous.Files)), int64(len(current.Files))); g > limit(d.MaxFilesIncrease) {
+		findings = append(findings, fmt.Sprintf("number of files of %s grew by %.0f%% from %d to %d since %s",
+			p.Name, g, len(previous.Files), len(current.Files), previous.Version))
+	}
+
+	if len(findings) > 0 && d.Fail {
+		return fmt.Errorf("%s", findings[0])
+	}
+	for _, f := range findings {
+		fmt.Printf("::warning::%s\n", f)
+		r.Warnings = append(r.Warnings, f)
+	}
+	return nil
+}
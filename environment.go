@@ -0,0 +1,98 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Environment records where and with which tools the packages were built
+// it allows reproducing old artifacts
+type Environment struct {
+	// RunnerOS and RunnerArch describe the runner the action was running on
+	RunnerOS   string `json:"runner_os"`
+	RunnerArch string `json:"runner_arch"`
+
+	// Action is the repository and ref of the action e.g. "paprikant/action-package@v1"
+	Action string `json:"action,omitempty"`
+
+	// ConfigHash is the sha256 checksum of packages.yml
+	ConfigHash string `json:"config_hash"`
+
+	// Tools maps the tools used to build the packages to their versions
+	Tools map[string]string `json:"tools"`
+}
+
+// tools whose versions are recorded and the arguments printing them
+var recordedTools = map[string][]string{
+	"fpm":      {"--version"},
+	"ruby":     {"--version"},
+	"rpmbuild": {"--version"},
+	"tar":      {"--version"},
+	"git":      {"--version"},
+	"gpg":      {"--version"},
+}
+
+// function toolVersion returns the first line printed by a tool asked for its version
+func toolVersion(name string, args ...string) (string, bool) {
+	output, err := exec.Command(name, args...).Output()
+	if err != nil {
+		return "", false
+	}
+	return strings.TrimSpace(strings.SplitN(string(output), "\n", 2)[0]), true
+}
+
+// method captureEnvironment records the environment of the build in the report
+// with environment_fields deb packages carry the most important details as control fields as well
+func (c *FPMConfig) captureEnvironment(config string) error {
+	contents, err := ioutil.ReadFile(config)
+	if err != nil {
+		return err
+	}
+	e := &Environment{
+		RunnerOS:   os.Getenv("RUNNER_OS"),
+		RunnerArch: os.Getenv("RUNNER_ARCH"),
+		ConfigHash: fmt.Sprintf("%x", sha256.Sum256(contents)),
+		Tools:      map[string]string{},
+	}
+	if e.RunnerOS == "" {
+		e.RunnerOS = runtime.GOOS
+	}
+	if e.RunnerArch == "" {
+		e.RunnerArch = runtime.GOARCH
+	}
+	if repository := os.Getenv("GITHUB_ACTION_REPOSITORY"); repository != "" {
+		e.Action = repository + "@" + os.Getenv("GITHUB_ACTION_REF")
+	}
+	for name, args := range recordedTools {
+		if version, ok := toolVersion(name, args...); ok {
+			e.Tools[name] = version
+		}
+	}
+	c.report.Environment = e
+
+	if !c.EnvironmentFields {
+		return nil
+	}
+	fields := []string{
+		"X-Build-Runner: " + e.RunnerOS + "/" + e.RunnerArch,
+		"X-Build-Config: sha256:" + e.ConfigHash,
+	}
+	if e.Action != "" {
+		fields = append(fields, "X-Build-Action: "+e.Action)
+	}
+	if version, ok := e.Tools["fpm"]; ok {
+		fields = append(fields, "X-Build-Fpm: "+version)
+	}
+	if c.report.Provenance != nil {
+		fields = append(fields, "X-Build-Commit: "+c.report.Provenance.Commit)
+	}
+	for i := range c.Packages {
+		c.Packages[i].buildFields = fields
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// function within decides whether path is inside of root, both have to be absolute
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
+
+// function traverses decides whether a path leaves its root using ".." components
+func traverses(path string) bool {
+	for _, part := range strings.Split(filepath.ToSlash(path), "/") {
+		if part == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// method checkTraversal fails for paths leaving the source root unless allow_outside_paths is set
+func (p *Package) checkTraversal() error {
+	for _, a := range p.Paths {
+		if src, _ := splitPath(a); traverses(src) && !p.AllowOutsidePaths {
+			return ConfigError{
+				packageEntry: p.Name,
+				field:        "paths",
+				message:      fmt.Sprintf(tr("path %s leaves the source root, set allow_outside_paths if this is intended"), a),
+			}
+		}
+	}
+	return nil
+}
+
+// method checkEscapes fails for symlinks of the package pointing outside of the source root
+// such links make the hosts the package is installed to expose or depend on files that are not part of the package
+// links to files installed by the package itself are fine, absolute links also to paths below its prefix
+func (p *Package) checkEscapes() error {
+	root := p.Source.Chdir
+	if root == "" {
+		root = "."
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+
+	files, err := p.contents()
+	if err != nil {
+		return err
+	}
+
+	// installed paths of the package including their parent directories
+	installed := map[string]bool{}
+	for _, f := range files {
+		for d := f.Target; d != "/"; d = filepath.Dir(d) {
+			installed[d] = true
+		}
+	}
+
+	for _, f := range files {
+		if f.Info.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+		link, err := os.Readlink(f.Source)
+		if err != nil {
+			return err
+		}
+		installedTarget := link
+		if !filepath.IsAbs(link) {
+			installedTarget = filepath.Join(filepath.Dir(f.Target), link)
+		}
+		if installed[filepath.Clean(installedTarget)] {
+			continue
+		}
+
+		// absolute targets are paths on the installing host, the build host is not asked for them
+		if filepath.IsAbs(link) {
+			if p.Target.Prefix != "" && within(filepath.Join("/", p.Target.Prefix), filepath.Clean(link)) {
+				continue
+			}
+			return fmt.Errorf("%s links to %s outside of the files installed by the package, set allow_outside_paths if this is intended", f.Target, link)
+		}
+
+		source, err := filepath.Abs(f.Source)
+		if err != nil {
+			return err
+		}
+		if !within(root, filepath.Join(filepath.Dir(source), link)) {
+			return fmt.Errorf("%s links to %s outside of the source root %s, set allow_outside_paths if this is intended", f.Target, link, root)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Expiry marks the packages of a run as outdated after some days, e.g. for nightly channels
+type Expiry struct {
+	// Days after the build the packages expire *REQUIRED*
+	Days int `yaml:"days"`
+
+	// Index is a json file listing the expiry of all packages of the channel *OPTIONAL*
+	// entries of previous runs are kept, so consumers and cleanup jobs find stale packages
+	Index string `yaml:"index"`
+
+	// at is the time the packages of this run expire
+	at time.Time
+}
+
+// ExpiryEntry is the expiry of a single package listed in the index
+type ExpiryEntry struct {
+	Name     string `json:"name"`
+	Mode     string `json:"mode"`
+	Version  string `json:"version"`
+	Artifact string `json:"artifact"`
+	Built    string `json:"built"`
+	Expires  string `json:"expires"`
+}
+
+// method check validates the expiry configuration
+func (e *Expiry) check() error {
+	if e.Days <= 0 {
+		return ConfigError{
+			field:   "expiry.days",
+			message: "the number of days until the packages expire is required and must be positive",
+		}
+	}
+	return nil
+}
+
+// method expires returns the time the packages of this run expire as listed in the report and index
+func (e *Expiry) expires() string {
+	return e.at.Format(time.RFC3339)
+}
+
+// method applyExpiry adds the expiry date as control field to deb packages
+// the date is formatted like the dates of apt Release files
+func (c *FPMConfig) applyExpiry() {
+	if c.Expiry == nil {
+		return
+	}
+	c.Expiry.at = buildTime().AddDate(0, 0, c.Expiry.Days)
+	field := "X-Expires: " + c.Expiry.at.Format(time.RFC1123Z)
+	for i := range c.Packages {
+		p := &c.Packages[i]
+		p.buildFields = append(append([]string{}, p.buildFields...), field)
+	}
+}
+
+// method writeExpiryIndex adds the successfully built packages to the expiry index
+// entries of the same artifact are replaced, the index is sorted by expiry
+func (c *FPMConfig) writeExpiryIndex() error {
+	if c.Expiry == nil || c.Expiry.Index == "" {
+		return nil
+	}
+	entries := []ExpiryEntry{}
+	contents, err := ioutil.ReadFile(c.Expiry.Index)
+	if err == nil {
+		if err := json.Unmarshal(contents, &entries); err != nil {
+			return fmt.Errorf("%s: %s", c.Expiry.Index, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	built := buildTime().Format(time.RFC3339)
+	for _, r := range c.report.Packages {
+		if r.Status != "success" || r.Expires == "" {
+			continue
+		}
+		e := ExpiryEntry{
+			Name:     r.Name,
+			Mode:     r.Mode,
+			Version:  r.Version,
+			Artifact: filepath.Base(r.Artifact),
+			Built:    built,
+			Expires:  r.Expires,
+		}
+		replaced := false
+		for i := range entries {
+			if entries[i].Artifact == e.Artifact {
+				entries[i] = e
+				replaced = true
+			}
+		}
+		if !replaced {
+			entries = append(entries, e)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Expires < entries[j].Expires
+	})
+
+	contents, err = json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.Expiry.Index), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.Expiry.Index, append(contents, '\n'), 0644)
+}
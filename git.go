@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+)
+
+// clones caches the checkouts of source mode git by url and ref
+// packages with several target modes are cloned only once
+var clones = map[string]string{}
+
+// function git runs a git command in dir
+func git(dir string, args ...string) error {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git %s failed: %s\n%s", args[0], err, output)
+	}
+	return nil
+}
+
+// function clone fetches a single ref of a repository into a temporary directory
+// the ref may be a branch, a tag or a commit, the default branch is used if it is empty
+func clone(url, ref string) (string, error) {
+	key := url + "@" + ref
+	if dir, ok := clones[key]; ok {
+		return dir, nil
+	}
+
+	dir, err := ioutil.TempDir("", "git-")
+	if err != nil {
+		return "", err
+	}
+	if ref == "" {
+		ref = "HEAD"
+	}
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"remote", "add", "origin", url},
+		{"fetch", "--quiet", "--depth", "1", "origin", ref},
+		{"checkout", "--quiet", "FETCH_HEAD"},
+	} {
+		if err := git(dir, args...); err != nil {
+			return "", err
+		}
+	}
+	clones[key] = dir
+	return dir, nil
+}
+
+// method checkout clones the repository of source mode git and turns the package into a dir package
+func (p *Package) checkout() error {
+	fmt.Printf(tr("cloning %s %s...\n"), p.Source.URL, p.Source.Ref)
+	dir, err := clone(p.Source.URL, p.Source.Ref)
+	if err != nil {
+		return err
+	}
+	commit, err := gitCommit(dir)
+	if err != nil {
+		return err
+	}
+	ref := p.Source.Ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+	p.remotes = append(p.remotes, remoteSource{"git " + p.Source.URL + "@" + ref, commit})
+
+	p.Source.Mode = "dir"
+	p.Source.Chdir = filepath.Join(dir, p.Source.Subdirectory)
+	p.Source.Excludes = append(append([]string{}, p.Source.Excludes...), ".git")
+	if len(p.Paths) == 0 {
+		p.Paths = []string{"."}
+	}
+	return nil
+}
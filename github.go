@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maximal time to wait for a rate limit to reset before giving up
+const maxRateLimitWait = 5 * time.Minute
+
+// githubClient is shared by all features that talk to the GitHub REST API
+// it retries failed requests, waits for rate limits and explains permission errors
+type githubClient struct {
+	api     string
+	token   string
+	client  *http.Client
+	retries int
+}
+
+// function newGitHubClient creates a client using GITHUB_API_URL and GITHUB_TOKEN
+func newGitHubClient() *githubClient {
+	api := os.Getenv("GITHUB_API_URL")
+	if api == "" {
+		api = "https://api.github.com"
+	}
+	return &githubClient{
+		api:     strings.TrimSuffix(api, "/"),
+		token:   os.Getenv("GITHUB_TOKEN"),
+		client:  &http.Client{Timeout: 60 * time.Second},
+		retries: 3,
+	}
+}
+
+// GitHubError is returned for requests the GitHub API rejected
+type GitHubError struct {
+	Method  string
+	Path    string
+	Status  int
+	Message string
+}
+
+// method Error provides a message for the GitHubError (and implements the Error interface)
+func (e GitHubError) Error() string {
+	return fmt.Sprintf("GitHub API %s %s failed with %d: %s", e.Method, e.Path, e.Status, e.Message)
+}
+
+// function permissionHint explains why a request was rejected for lack of permissions
+// classic tokens list their scopes in headers, GITHUB_TOKEN permissions are set in the workflow
+func permissionHint(resp *http.Response) string {
+	accepted := resp.Header.Get("X-Accepted-OAuth-Scopes")
+	granted := resp.Header.Get("X-OAuth-Scopes")
+	if accepted != "" {
+		return fmt.Sprintf("the token needs one of the scopes %q but has %q", accepted, granted)
+	}
+	return "the token lacks permissions, grant e.g. `permissions: contents: write` to the workflow job"
+}
+
+// function rateLimitWait returns how long to wait before retrying a rate limited request
+// ok is false if the response was not caused by a rate limit
+func rateLimitWait(resp *http.Response) (wait time.Duration, ok bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+	if after, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		return time.Duration(after) * time.Second, true
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+		if err != nil {
+			return time.Minute, true
+		}
+		return time.Until(time.Unix(reset, 0)) + time.Second, true
+	}
+	return 0, false
+}
+
+// method request sends a request to the GitHub API
+// body is encoded as json, the json response is decoded into result if it is not nil
+func (g *githubClient) request(method, path string, body interface{}, result interface{}) error {
+	if g.token == "" {
+		return fmt.Errorf("GITHUB_TOKEN is not set, pass it to the action using env: GITHUB_TOKEN: ${{ secrets.GITHUB_TOKEN }}")
+	}
+
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		req, err := http.NewRequest(method, g.api+path, bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+g.token)
+		req.Header.Set("Accept", "application/vnd.github+json")
+		if payload != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		resp, err := g.client.Do(req)
+		if err != nil {
+			// network errors are retried
+			if attempt < g.retries {
+				time.Sleep(backoff)
+				backoff *= 2
+				continue
+			}
+			return err
+		}
+
+		contents, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		if resp.StatusCode < 300 {
+			if result != nil && len(contents) > 0 {
+				return json.Unmarshal(contents, result)
+			}
+			return nil
+		}
+
+		message := struct {
+			Message string `json:"message"`
+		}{}
+		json.Unmarshal(contents, &message)
+		apiErr := GitHubError{Method: method, Path: path, Status: resp.StatusCode, Message: message.Message}
+
+		// wait for rate limits to reset
+		if wait, ok := rateLimitWait(resp); ok && attempt < g.retries {
+			if wait > maxRateLimitWait {
+				apiErr.Message = fmt.Sprintf("rate limit exceeded, resets in %s", wait.Round(time.Second))
+				return apiErr
+			}
+			fmt.Printf("GitHub API rate limit reached, waiting %s\n", wait.Round(time.Second))
+			time.Sleep(wait)
+			continue
+		}
+
+		switch {
+		case resp.StatusCode == http.StatusUnauthorized:
+			apiErr.Message = "GITHUB_TOKEN is invalid or expired"
+			return apiErr
+		case resp.StatusCode == http.StatusForbidden, resp.StatusCode == http.StatusNotFound:
+			// GitHub answers 404 instead of 403 for resources the token may not see
+			apiErr.Message = fmt.Sprintf("%s (%s)", message.Message, permissionHint(resp))
+			return apiErr
+		case resp.StatusCode >= 500 && attempt < g.retries:
+			time.Sleep(backoff)
+			backoff *= 2
+			continue
+		}
+		return apiErr
+	}
+}
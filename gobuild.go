@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+)
+
+// goArchitectures maps package architectures to GOARCH
+var goArchitectures = map[string]string{
+	"amd64":   "amd64",
+	"x86_64":  "amd64",
+	"arm64":   "arm64",
+	"aarch64": "arm64",
+	"armhf":   "arm",
+	"armv7hl": "arm",
+	"i386":    "386",
+	"i686":    "386",
+}
+
+// goOperatingSystems maps target modes that are not built for linux to GOOS
+var goOperatingSystems = map[string]string{
+	"freebsd": "freebsd",
+	"osxpkg":  "darwin",
+}
+
+// method goEnv returns the environment of go build
+// GOOS and GOARCH default to the target mode and architecture of the package, cgo is disabled unless set
+func (p *Package) goEnv() []string {
+	goos := p.Source.GOOS
+	if goos == "" {
+		goos = goOperatingSystems[p.Target.Mode]
+	}
+	goarch := p.Source.GOARCH
+	if goarch == "" {
+		goarch = goArchitectures[p.Target.Architecture]
+	}
+
+	env := os.Environ()
+	if goos != "" {
+		env = append(env, "GOOS="+goos)
+	}
+	if goarch != "" {
+		env = append(env, "GOARCH="+goarch)
+	}
+	if goarch == "arm" && p.Source.GOARCH == "" {
+		env = append(env, "GOARM=7")
+	}
+	if os.Getenv("CGO_ENABLED") == "" {
+		env = append(env, "CGO_ENABLED=0")
+	}
+	return env
+}
+
+// method goBuild compiles the main packages of source mode go and turns the package into a dir package
+// the binaries are added to the paths, other paths are relative to the module like for mode dir
+func (p *Package) goBuild() error {
+	module := p.Source.Chdir
+	if module == "" {
+		module = "."
+	}
+	module, err := filepath.Abs(module)
+	if err != nil {
+		return err
+	}
+	installPath := p.Source.InstallPath
+	if installPath == "" {
+		installPath = "/usr/bin"
+	}
+
+	dir, err := ioutil.TempDir("", "go-")
+	if err != nil {
+		return err
+	}
+	paths := []string{}
+	for _, main := range p.Source.Main {
+		name := path.Base(main)
+		if name == "." {
+			name = filepath.Base(module)
+		}
+		binary := filepath.Join(dir, name)
+
+		fmt.Printf(tr("building %s...\n"), main)
+		cmdName, cmdArgs := p.Resources.command("go", "build", "-trimpath", "-o", binary, main)
+		cmd := exec.Command(cmdName, cmdArgs...)
+		cmd.Dir = module
+		cmd.Env = p.goEnv()
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("go build %s failed: %s\n%s", main, err, output)
+		}
+		paths = append(paths, binary+"="+path.Join(installPath, name))
+	}
+
+	p.Source.Mode = "dir"
+	p.Source.Chdir = module
+	p.Paths = append(paths, p.Paths...)
+	return nil
+}
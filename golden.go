@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// function normalize replaces paths of temporary directories as they change with every run
+func normalize(arg string) string {
+	tmp := strings.TrimSuffix(os.TempDir(), "/") + "/"
+	if strings.HasPrefix(arg, tmp) {
+		return "${TMPDIR}/" + strings.SplitN(strings.TrimPrefix(arg, tmp), "/", 2)[0]
+	}
+	return arg
+}
+
+// method goldenLines renders the fpm invocation of the package
+// every flag is written on one line with its values, every path on a line of its own
+func (p *Package) goldenLines() []string {
+	lines := []string{"fpm"}
+	for _, a := range p.args(nil) {
+		if strings.HasPrefix(a, "-") {
+			lines = append(lines, normalize(a))
+			continue
+		}
+		lines[len(lines)-1] += " " + normalize(a)
+	}
+	for _, a := range p.Paths {
+		lines = append(lines, "path "+normalize(a))
+	}
+	return lines
+}
+
+// method goldenFile returns the name of the golden file of the package
+func (p *Package) goldenFile() string {
+	return fmt.Sprintf("%s_%s.golden", p.Name, p.Target.Mode)
+}
+
+// method golden compares the resolved fpm invocation of every package with the files in dir
+//
+// nothing is built, missing golden files are created. update overwrites all golden files.
+// committing the golden files makes unintended packaging changes visible in pull requests
+func (c *FPMConfig) golden(dir string, update bool) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	changed := []string{}
+	for _, p := range c.Packages {
+		lines := p.goldenLines()
+		current := strings.Join(lines, "\n") + "\n"
+		path := filepath.Join(dir, p.goldenFile())
+
+		previous, err := ioutil.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err == nil && !update {
+			if string(previous) == current {
+				continue
+			}
+			fmt.Printf(tr("%s package %s differs from %s:\n"), p.Target.Mode, p.Name, path)
+			added, removed := diffList(strings.Split(strings.TrimSpace(string(previous)), "\n"), lines)
+			for _, r := range removed {
+				fmt.Printf("  - %s\n", r)
+			}
+			for _, a := range added {
+				fmt.Printf("  + %s\n", a)
+			}
+			if len(added) == 0 && len(removed) == 0 {
+				fmt.Printf(tr("  the order of the arguments changed\n"))
+			}
+			changed = append(changed, p.goldenFile())
+			continue
+		}
+
+		if err := ioutil.WriteFile(path, []byte(current), 0644); err != nil {
+			return err
+		}
+		fmt.Printf(tr("wrote %s\n"), path)
+	}
+
+	if len(changed) > 0 {
+		return fmt.Errorf("packaging changed for %s, run with --update-golden if the changes are intended", strings.Join(changed, ", "))
+	}
+	return nil
+}
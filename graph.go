@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+// graphEdge is a relationship between two packages declared in the configuration
+type graphEdge struct {
+	From string
+	To   string
+
+	// Kind is "depends", "conflicts", "provides", "replaces" or "breaks"
+	Kind string
+}
+
+// function relationNames returns the package names of a relationship entry like "a (>= 1.0) | b"
+func relationNames(entry string) []string {
+	names := []string{}
+	for _, alternative := range strings.Split(entry, "|") {
+		if fields := strings.Fields(alternative); len(fields) > 0 {
+			names = append(names, fields[0])
+		}
+	}
+	return names
+}
+
+// method graphEdges collects the relationships of all packages, entries expanded from several target modes are merged
+func (c *FPMConfig) graphEdges() (packages []string, edges []graphEdge) {
+	seen := map[graphEdge]bool{}
+	add := func(e graphEdge) {
+		if !seen[e] {
+			seen[e] = true
+			edges = append(edges, e)
+		}
+	}
+	for _, p := range c.Packages {
+		if !contains(packages, p.Name) {
+			packages = append(packages, p.Name)
+		}
+		for _, d := range append(append([]string{}, p.Target.Depends...), p.Target.PreDepends...) {
+			for _, n := range relationNames(d) {
+				add(graphEdge{p.Name, n, "depends"})
+			}
+		}
+		for _, d := range p.Target.Conflicts {
+			for _, n := range relationNames(d) {
+				add(graphEdge{p.Name, n, "conflicts"})
+			}
+		}
+		for _, d := range p.Target.Provides {
+			for _, n := range relationNames(d) {
+				add(graphEdge{p.Name, n, "provides"})
+			}
+		}
+		for _, d := range p.Target.Replaces {
+			for _, n := range relationNames(d) {
+				add(graphEdge{p.Name, n, "replaces"})
+			}
+		}
+		for _, d := range p.Target.Breaks {
+			for _, n := range relationNames(d) {
+				add(graphEdge{p.Name, n, "breaks"})
+			}
+		}
+	}
+	return packages, edges
+}
+
+// function graphNodes returns all nodes of the graph, packages of the configuration first
+func graphNodes(packages []string, edges []graphEdge) []string {
+	external := []string{}
+	for _, e := range edges {
+		if !contains(packages, e.To) && !contains(external, e.To) {
+			external = append(external, e.To)
+		}
+	}
+	sort.Strings(external)
+	return append(append([]string{}, packages...), external...)
+}
+
+// function renderDOT renders the graph in the DOT language of graphviz
+// packages that are not part of the configuration are drawn dashed
+func renderDOT(packages []string, edges []graphEdge) string {
+	styles := map[string]string{
+		"depends":   "",
+		"conflicts": ` [color=red, label="conflicts"]`,
+		"provides":  ` [style=dotted, label="provides"]`,
+		"replaces":  ` [style=dotted, label="replaces"]`,
+		"breaks":    ` [color=red, style=dotted, label="breaks"]`,
+	}
+	b := &strings.Builder{}
+	b.WriteString("digraph packages {\n")
+	for _, n := range graphNodes(packages, edges) {
+		if contains(packages, n) {
+			fmt.Fprintf(b, "  %q [shape=box];\n", n)
+		} else {
+			fmt.Fprintf(b, "  %q [style=dashed];\n", n)
+		}
+	}
+	for _, e := range edges {
+		fmt.Fprintf(b, "  %q -> %q%s;\n", e.From, e.To, styles[e.Kind])
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// function renderMermaid renders the graph as mermaid flowchart, which GitHub renders in markdown
+// node ids are generated since package names may contain characters mermaid does not accept
+func renderMermaid(packages []string, edges []graphEdge) string {
+	arrows := map[string]string{
+		"depends":   "-->",
+		"conflicts": "-. conflicts .->",
+		"provides":  "-. provides .->",
+		"replaces":  "-. replaces .->",
+		"breaks":    "-. breaks .->",
+	}
+	ids := map[string]string{}
+	b := &strings.Builder{}
+	b.WriteString("flowchart LR\n")
+	for i, n := range graphNodes(packages, edges) {
+		ids[n] = fmt.Sprintf("n%d", i)
+		if contains(packages, n) {
+			fmt.Fprintf(b, "  %s[\"%s\"]\n", ids[n], n)
+		} else {
+			fmt.Fprintf(b, "  %s([\"%s\"])\n", ids[n], n)
+		}
+	}
+	for _, e := range edges {
+		fmt.Fprintf(b, "  %s %s %s\n", ids[e.From], arrows[e.Kind], ids[e.To])
+	}
+	return b.String()
+}
+
+// method graph renders the relationships of all packages as "dot" or "mermaid"
+//
+// the graph is printed and written to output if it is set. within GitHub Actions a mermaid
+// rendering is added to the job summary, which displays it as diagram
+func (c *FPMConfig) graph(format, output string) error {
+	packages, edges := c.graphEdges()
+
+	var rendered string
+	switch format {
+	case "dot":
+		rendered = renderDOT(packages, edges)
+	case "mermaid":
+		rendered = renderMermaid(packages, edges)
+	default:
+		return fmt.Errorf("unknown graph format %s, valid formats are dot|mermaid", format)
+	}
+
+	fmt.Print(rendered)
+	if output != "" {
+		if err := ioutil.WriteFile(output, []byte(rendered), 0644); err != nil {
+			return err
+		}
+	}
+	if os.Getenv("GITHUB_STEP_SUMMARY") != "" {
+		return appendJobSummary("# Package relationships\n\n```mermaid\n" + renderMermaid(packages, edges) + "```\n")
+	}
+	return nil
+}
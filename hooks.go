@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// Hooks are shell commands run at fixed points of building a package
+type Hooks struct {
+	// PostStage runs after the files were staged and before fpm runs
+	// STAGING_DIR points to the directory fpm packages, changes to it end up in the package
+	PostStage []string `yaml:"post_stage"`
+
+	// PostBuild runs after fpm created the package and before it is signed, scanned and published
+	// ARTIFACT points to the created package
+	PostBuild []string `yaml:"post_build"`
+}
+
+// method hookEnv returns the environment passed to the hooks of the package
+func (p *Package) hookEnv() []string {
+	staging := p.Source.Chdir
+	if staging == "" {
+		staging = "."
+	}
+	if abs, err := filepath.Abs(staging); err == nil {
+		staging = abs
+	}
+	return append(os.Environ(),
+		"PACKAGE_NAME="+p.Name,
+		"PACKAGE_VERSION="+p.Target.Version,
+		"PACKAGE_MODE="+p.Target.Mode,
+		"STAGING_DIR="+staging,
+	)
+}
+
+// function runHooks runs the commands of a hook in order and stops at the first failing command
+func runHooks(stage string, commands []string, env []string) error {
+	for _, h := range commands {
+		fmt.Printf(tr("running %s hook: %s\n"), stage, h)
+		cmd := exec.Command("sh", "-c", h)
+		cmd.Env = env
+		output, err := cmd.CombinedOutput()
+		fmt.Printf("%s", output)
+		if err != nil {
+			return fmt.Errorf("%s hook %q failed: %s", stage, h, err)
+		}
+	}
+	return nil
+}
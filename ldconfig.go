@@ -0,0 +1,108 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// directories searched by the dynamic linker, multiarch directories like /usr/lib/x86_64-linux-gnu are added below
+var libraryDirs = []string{"/lib", "/lib32", "/lib64", "/usr/lib", "/usr/lib32", "/usr/lib64", "/usr/local/lib"}
+
+// file names of shared libraries e.g. libexample.so.1.2
+var sharedLibrary = regexp.MustCompile(`^lib.*\.so(\.[0-9]+)*$`)
+
+// function libraryPath decides whether target is a shared library in a directory of the dynamic linker
+func libraryPath(target string) bool {
+	if !sharedLibrary.MatchString(path.Base(target)) {
+		return false
+	}
+	dir := path.Dir(target)
+	if contains(libraryDirs, dir) {
+		return true
+	}
+	return contains(libraryDirs, path.Dir(dir)) && strings.Contains(path.Base(dir), "-linux-")
+}
+
+// method sharedLibraries lists the shared libraries the package installs to directories of the dynamic linker
+func (p *Package) sharedLibraries() ([]string, error) {
+	files, err := p.contents()
+	if err != nil {
+		return nil, err
+	}
+	libraries := []string{}
+	for _, f := range files {
+		if !f.Info.IsDir() && libraryPath(f.Target) {
+			libraries = append(libraries, f.Target)
+		}
+	}
+	return libraries, nil
+}
+
+// function withLdconfig returns a copy of a maintainer script running ldconfig first
+// scripts already calling ldconfig are returned unchanged, an empty script creates a new one
+func withLdconfig(script string) (string, error) {
+	contents := "#!/bin/sh\n"
+	if script != "" {
+		b, err := ioutil.ReadFile(script)
+		if err != nil {
+			return "", err
+		}
+		if strings.Contains(string(b), "ldconfig") {
+			return script, nil
+		}
+		contents = string(b)
+	}
+
+	// the call goes right after the interpreter line, so an early exit of the script does not skip it
+	head, tail := "", contents
+	if strings.HasPrefix(contents, "#!") {
+		if i := strings.Index(contents, "\n"); i >= 0 {
+			head, tail = contents[:i+1], contents[i+1:]
+		} else {
+			head, tail = contents+"\n", ""
+		}
+	}
+
+	f, err := ioutil.TempFile("", "ldconfig-")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.WriteString(head + "/sbin/ldconfig\n" + tail); err != nil {
+		return "", err
+	}
+	return f.Name(), os.Chmod(f.Name(), 0755)
+}
+
+// method addLdconfig makes the dynamic linker find the shared libraries of the package once it is installed
+//
+// deb packages activate the ldconfig trigger of libc-bin, which runs ldconfig once after all packages
+// are unpacked, unless their triggers activate it already. rpm packages run ldconfig in after_install
+// and after_remove. it returns the libraries found
+func (p *Package) addLdconfig() ([]string, error) {
+	if p.Target.Ldconfig != nil && !*p.Target.Ldconfig {
+		return nil, nil
+	}
+	if p.Target.Mode != "deb" && p.Target.Mode != "rpm" {
+		return nil, nil
+	}
+	libraries, err := p.sharedLibraries()
+	if err != nil || len(libraries) == 0 {
+		return nil, err
+	}
+
+	if p.Target.Mode == "deb" {
+		p.ldconfig = !p.activates("ldconfig")
+		return libraries, nil
+	}
+	if p.Target.AfterInstall, err = withLdconfig(p.Target.AfterInstall); err != nil {
+		return nil, err
+	}
+	if p.Target.AfterRemove, err = withLdconfig(p.Target.AfterRemove); err != nil {
+		return nil, err
+	}
+	return libraries, nil
+}
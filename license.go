@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// LicenseAudit configures the scan of package contents for third party licenses
+type LicenseAudit struct {
+	// Notices is the path the generated third party notices file is installed to
+	// defaults to /usr/share/doc/<package name>/THIRD_PARTY_NOTICES
+	Notices string `yaml:"notices"`
+
+	// SourceOffer is a file with the written offer to provide the sources of GPL and LGPL components
+	// it is installed next to the notices as SOURCE_OFFER if such components are found
+	SourceOffer string `yaml:"source_offer"`
+
+	// RequireSourceOffer fails the build if GPL or LGPL components are found and no source offer is given
+	RequireSourceOffer bool `yaml:"require_source_offer"`
+}
+
+// LicenseFinding is a single third party component found in the package contents
+type LicenseFinding struct {
+	// Component is the name (and version if known) of the third party component
+	Component string `json:"component"`
+
+	// License is the identified license or "unknown"
+	License string `json:"license"`
+
+	// File is the path inside the package the finding was made in
+	File string `json:"file"`
+
+	// text of the license file, if the finding was made from one
+	text string
+}
+
+// file names that usually carry license information
+var licenseFilePattern = regexp.MustCompile(`(?i)^(license|licence|copying|notice)([.-].*)?$`)
+
+// go binaries embed their module dependencies as "dep\t<path>\t<version>" lines
+var goDependencyPattern = regexp.MustCompile(`(?m)^dep\t(\S+)\t(\S+)`)
+
+// marker of the build information section in go binaries
+var goBuildInfoMagic = []byte("\xff Go buildinf:")
+
+// well known license texts and how to recognize them
+var licenseSignatures = []struct {
+	name   string
+	marker string
+}{
+	{"Apache-2.0", "Apache License"},
+	{"MIT", "Permission is hereby granted, free of charge"},
+	{"GPL-3.0", "GNU GENERAL PUBLIC LICENSE\n                       Version 3"},
+	{"GPL-2.0", "GNU GENERAL PUBLIC LICENSE\n                       Version 2"},
+	{"LGPL", "GNU LESSER GENERAL PUBLIC LICENSE"},
+	{"MPL-2.0", "Mozilla Public License Version 2.0"},
+	{"BSD-3-Clause", "Neither the name of"},
+	{"BSD-2-Clause", "Redistribution and use in source and binary forms"},
+	{"ISC", "Permission to use, copy, modify, and/or distribute this software"},
+}
+
+// function identifyLicense guesses the license of a license text
+func identifyLicense(text string) string {
+	for _, s := range licenseSignatures {
+		if strings.Contains(text, s.marker) {
+			return s.name
+		}
+	}
+	return "unknown"
+}
+
+// function copyleft decides whether a license obliges distributors to provide the sources, i.e. GPL, LGPL and AGPL
+func copyleft(license string) bool {
+	return strings.Contains(strings.ToUpper(license), "GPL")
+}
+
+// function copyleftComponents lists the components of the findings that come with the obligation to provide the sources
+func copyleftComponents(findings []LicenseFinding) []string {
+	components := []string{}
+	for _, f := range findings {
+		if copyleft(f.License) && !contains(components, f.Component) {
+			components = append(components, f.Component)
+		}
+	}
+	return components
+}
+
+// method check validates the source offer
+func (l *LicenseAudit) check(name string) error {
+	if l.SourceOffer == "" {
+		return nil
+	}
+	if info, err := os.Stat(l.SourceOffer); err != nil || info.Size() == 0 {
+		return ConfigError{
+			packageEntry: name,
+			field:        "license_audit.source_offer",
+			message:      fmt.Sprintf(tr("the source offer %s does not exist or is empty"), l.SourceOffer),
+		}
+	}
+	return nil
+}
+
+// method noticesPath returns the install path of the third party notices file
+func (l *LicenseAudit) noticesPath(name string) string {
+	if l.Notices == "" {
+		return fmt.Sprintf("/usr/share/doc/%s/THIRD_PARTY_NOTICES", name)
+	}
+	return l.Notices
+}
+
+// method auditLicenses scans the package contents for license files, node modules and go binaries
+func (p *Package) auditLicenses() ([]LicenseFinding, error) {
+	findings := []LicenseFinding{}
+
+	contents, err := p.contents()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range contents {
+		if !f.Info.Mode().IsRegular() {
+			continue
+		}
+		name := filepath.Base(f.Target)
+
+		switch {
+		// plain license files are attributed to the directory they are located in
+		case licenseFilePattern.MatchString(name):
+			text, err := ioutil.ReadFile(f.Source)
+			if err != nil {
+				return nil, err
+			}
+			findings = append(findings, LicenseFinding{
+				Component: filepath.Base(filepath.Dir(f.Target)),
+				License:   identifyLicense(string(text)),
+				File:      f.Target,
+				text:      string(text),
+			})
+
+		// node modules declare their license in package.json
+		case name == "package.json" && strings.Contains(f.Target, "/node_modules/"):
+			text, err := ioutil.ReadFile(f.Source)
+			if err != nil {
+				return nil, err
+			}
+			module := struct {
+				Name    string      `json:"name"`
+				Version string      `json:"version"`
+				License interface{} `json:"license"`
+			}{}
+			if err := json.Unmarshal(text, &module); err != nil || module.Name == "" {
+				continue
+			}
+			license := "unknown"
+			if l, ok := module.License.(string); ok && l != "" {
+				license = l
+			}
+			findings = append(findings, LicenseFinding{
+				Component: module.Name + "@" + module.Version,
+				License:   license,
+				File:      f.Target,
+			})
+
+		// go binaries carry a list of their module dependencies
+		case f.Info.Mode()&0111 != 0:
+			binary, err := ioutil.ReadFile(f.Source)
+			if err != nil {
+				return nil, err
+			}
+			if !bytes.Contains(binary, goBuildInfoMagic) {
+				continue
+			}
+			for _, m := range goDependencyPattern.FindAllSubmatch(binary, -1) {
+				findings = append(findings, LicenseFinding{
+					Component: string(m[1]) + "@" + string(m[2]),
+					License:   "unknown",
+					File:      f.Target,
+				})
+			}
+		}
+	}
+
+	return findings, nil
+}
+
+// function renderNotices creates the contents of the third party notices file
+func renderNotices(name string, findings []LicenseFinding) string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "Third party notices for %s\n\n", name)
+	for _, f := range findings {
+		fmt.Fprintf(b, "%s: %s (%s)\n", f.Component, f.License, f.File)
+	}
+	for _, f := range findings {
+		if f.text == "" {
+			continue
+		}
+		fmt.Fprintf(b, "\n\n==== %s (%s) ====\n\n%s", f.Component, f.File, f.text)
+	}
+	return b.String()
+}
+
+// method licenseNotices runs the license audit, writes the notices file and records the findings
+// it returns the path arguments that add the notices file and, for GPL and LGPL components, the source offer
+func (p *Package) licenseNotices(r *PackageReport) ([]string, error) {
+	findings, err := p.auditLicenses()
+	if err != nil {
+		return nil, err
+	}
+	r.Licenses = findings
+	for _, f := range findings {
+		if f.License == "unknown" {
+			r.Warnings = append(r.Warnings, fmt.Sprintf("license of %s could not be identified (%s)", f.Component, f.File))
+		}
+	}
+
+	notices, err := ioutil.TempFile("", p.Name+"-notices-")
+	if err != nil {
+		return nil, err
+	}
+	defer notices.Close()
+	if err := notices.Chmod(0644); err != nil {
+		return nil, err
+	}
+	if _, err := notices.WriteString(renderNotices(p.Name, findings)); err != nil {
+		return nil, err
+	}
+
+	notice, err := p.pathArgument(notices.Name(), p.LicenseAudit.noticesPath(p.Name))
+	if err != nil {
+		return nil, err
+	}
+	paths := []string{notice}
+
+	// the offer to provide the sources has to be distributed along with GPL and LGPL components
+	components := copyleftComponents(findings)
+	if len(components) == 0 {
+		return paths, nil
+	}
+	if p.LicenseAudit.SourceOffer == "" {
+		if p.LicenseAudit.RequireSourceOffer {
+			return nil, licenseObligationError{components}
+		}
+		r.Warnings = append(r.Warnings, fmt.Sprintf("GPL or LGPL components without source offer: %s", strings.Join(components, ", ")))
+		return paths, nil
+	}
+	source, err := filepath.Abs(p.LicenseAudit.SourceOffer)
+	if err != nil {
+		return nil, err
+	}
+	offer, err := p.pathArgument(source, path.Join(path.Dir(p.LicenseAudit.noticesPath(p.Name)), "SOURCE_OFFER"))
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf(tr("package %s contains %d GPL or LGPL components, adding the source offer %s\n"),
+		p.Name, len(components), p.LicenseAudit.SourceOffer)
+	return append(paths, offer), nil
+}
+
+// licenseObligationError lists the components whose licenses require a source offer the package lacks
+type licenseObligationError struct {
+	components []string
+}
+
+// method Error provides a message for the licenseObligationError (and implements the Error interface)
+func (e licenseObligationError) Error() string {
+	return fmt.Sprintf("GPL or LGPL components require a source offer, set license_audit.source_offer: %s",
+		strings.Join(e.components, ", "))
+}
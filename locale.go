@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// method applyLocale makes the run independent of the timezone and locale of the runner
+//
+// TZ and LC_ALL are set for the action and all commands it runs, so timestamps and changelog dates
+// written by fpm are in the same timezone and tools sort and format the same on every runner
+// timezone defaults to "UTC", locale to "C"
+func (c *FPMConfig) applyLocale() error {
+	timezone := c.Timezone
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	locale := c.Locale
+	if locale == "" {
+		locale = "C"
+	}
+
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return ConfigError{
+			field:   "timezone",
+			message: fmt.Sprintf(tr("unknown timezone %s"), timezone),
+		}
+	}
+	time.Local = location
+	os.Setenv("TZ", timezone)
+	os.Setenv("LC_ALL", locale)
+	return nil
+}
+
+// function buildTime returns the timestamp used for generated files
+// SOURCE_DATE_EPOCH is used if set to allow reproducible builds
+func buildTime() time.Time {
+	if epoch, err := strconv.ParseInt(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64); err == nil {
+		return time.Unix(epoch, 0).UTC()
+	}
+	return time.Now().UTC()
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// name of the lockfile written next to packages.yml
+const lockFile = "packages.lock"
+
+// Lock pins everything besides the workspace the packages are built from
+// builds with --locked fail if any of it changed
+type Lock struct {
+	// ConfigHash is the sha256 checksum of packages.yml
+	ConfigHash string `json:"config_hash"`
+
+	// Tools maps the tools used to build the packages to their versions
+	Tools map[string]string `json:"tools"`
+
+	// Gems maps the installed ruby gems, i.e. fpm and its plugins, to their versions
+	Gems map[string]string `json:"gems"`
+
+	// Sources maps the remote sources of the packages to the digests they resolved to
+	// e.g. "git https://github.com/example/example@main" to the commit
+	Sources map[string]string `json:"sources"`
+}
+
+// remoteSource is a source of a package fetched while building and the digest it resolved to
+type remoteSource struct {
+	key    string
+	digest string
+}
+
+// function installedGems returns the versions of the installed ruby gems
+func installedGems() map[string]string {
+	gems := map[string]string{}
+	output, err := exec.Command("gem", "list", "--local").Output()
+	if err != nil {
+		return gems
+	}
+	// lines look like "fpm (1.15.1)" or "json (default: 2.6.3, 2.5.1)"
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), " (", 2)
+		if len(parts) == 2 {
+			gems[parts[0]] = strings.TrimSuffix(parts[1], ")")
+		}
+	}
+	return gems
+}
+
+// function gitCommit returns the commit checked out in dir
+func gitCommit(dir string) (string, error) {
+	output, err := exec.Command("git", "-C", dir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "", fmt.Errorf("could not resolve the commit of %s: %s", dir, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// function currentLock records the configuration, tools and gems of this run
+func currentLock(config string) (*Lock, error) {
+	contents, err := ioutil.ReadFile(config)
+	if err != nil {
+		return nil, err
+	}
+	l := &Lock{
+		ConfigHash: fmt.Sprintf("%x", sha256.Sum256(contents)),
+		Tools:      map[string]string{},
+		Gems:       installedGems(),
+		Sources:    map[string]string{},
+	}
+	for name, args := range recordedTools {
+		if version, ok := toolVersion(name, args...); ok {
+			l.Tools[name] = version
+		}
+	}
+	return l, nil
+}
+
+// function lockDrift lists the differences between the locked and the current versions of a kind
+func lockDrift(kind string, locked, current map[string]string) []string {
+	names := []string{}
+	for name := range locked {
+		names = append(names, name)
+	}
+	for name := range current {
+		if _, ok := locked[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	drift := []string{}
+	for _, name := range names {
+		if locked[name] != current[name] {
+			drift = append(drift, fmt.Sprintf("%s %s: locked %q, found %q", kind, name, locked[name], current[name]))
+		}
+	}
+	return drift
+}
+
+// method startLock records the run for the lockfile
+// with locked the configuration, tools and gems have to match packages.lock, remote sources are compared later
+func (c *FPMConfig) startLock(config string, locked bool) error {
+	current, err := currentLock(config)
+	if err != nil {
+		return err
+	}
+	c.lock = current
+	if !locked {
+		return nil
+	}
+
+	contents, err := ioutil.ReadFile(lockFile)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("there is no %s to build --locked, create it with --lock", lockFile)
+	}
+	if err != nil {
+		return err
+	}
+	c.locked = &Lock{}
+	if err := json.Unmarshal(contents, c.locked); err != nil {
+		return fmt.Errorf("%s: %s", lockFile, err)
+	}
+
+	drift := []string{}
+	if c.locked.ConfigHash != current.ConfigHash {
+		drift = append(drift, fmt.Sprintf("%s changed", config))
+	}
+	drift = append(drift, lockDrift("tool", c.locked.Tools, current.Tools)...)
+	drift = append(drift, lockDrift("gem", c.locked.Gems, current.Gems)...)
+	if len(drift) > 0 {
+		return fmt.Errorf("the build drifted from %s:\n  %s", lockFile, strings.Join(drift, "\n  "))
+	}
+	return nil
+}
+
+// method lockSource records the digest of a remote source, it has to match packages.lock with --locked
+func (c *FPMConfig) lockSource(s remoteSource) error {
+	if c.lock == nil || s.key == "" {
+		return nil
+	}
+	c.lock.Sources[s.key] = s.digest
+	if c.locked == nil {
+		return nil
+	}
+	expected, ok := c.locked.Sources[s.key]
+	if !ok {
+		return fmt.Errorf("source %s is not in %s", s.key, lockFile)
+	}
+	if expected != s.digest {
+		return fmt.Errorf("source %s drifted from %s: locked %s, found %s", s.key, lockFile, expected, s.digest)
+	}
+	return nil
+}
+
+// method writeLock writes the recorded run to packages.lock
+func (c *FPMConfig) writeLock() error {
+	contents, err := json.MarshalIndent(c.lock, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Printf(tr("writing %s\n"), lockFile)
+	return ioutil.WriteFile(lockFile, append(contents, '\n'), 0644)
+}
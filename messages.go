@@ -0,0 +1,248 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// language of the messages printed by the action, messages missing in a catalog are printed in English
+var language = "en"
+
+// catalogs map English messages to their translations by language
+// keys are the messages as written in the code, format strings keep their verbs in the same order
+var catalogs = map[string]map[string]string{
+	"en": {},
+	"de": {
+		// framing of configuration errors
+		"error in package %s:\n  -> config field %s missing or invalid\n  -> %s\n": "Fehler in Paket %s:\n  -> Feld %s fehlt oder ist ungültig\n  -> %s\n",
+
+		// messages of the command line
+		"packages.yml specifies no packages to build\n": "packages.yml enthält keine Pakete\n",
+		"FPM command failed\n":                          "Der Aufruf von fpm ist fehlgeschlagen\n",
+		"unknown command %s, valid commands are build|promote|restore|graph|doctor|train\n": "unbekannter Befehl %s, gültig sind build|promote|restore|graph|doctor|train\n",
+		"building %s package %s...\n": "baue %s-Paket %s...\n",
+
+		// configuration errors
+		"name is required":                                                                 "ein Name ist erforderlich",
+		"debian packages require a version":                                                "Debian-Pakete benötigen eine Version",
+		"rpm packages require a version":                                                   "rpm-Pakete benötigen eine Version",
+		"osxpkg packages require a version":                                                "osxpkg-Pakete benötigen eine Version",
+		"sh installers require a version":                                                  "sh-Installer benötigen eine Version",
+		"rpm versions must not contain dashes":                                             "rpm-Versionen dürfen keine Bindestriche enthalten",
+		"apk packages require a version like 1.2.3, 1.2.3_rc1 or 1.2.3-r1":                 "apk-Pakete benötigen eine Version wie 1.2.3, 1.2.3_rc1 oder 1.2.3-r1",
+		"pacman packages require a version without dashes, colons, slashes or spaces":      "pacman-Pakete benötigen eine Version ohne Bindestriche, Doppelpunkte, Schrägstriche und Leerzeichen",
+		"freebsd packages require a version without dashes, underscores, commas or spaces": "freebsd-Pakete benötigen eine Version ohne Bindestriche, Unterstriche, Kommas und Leerzeichen",
+		"freebsd package names must not contain spaces or slashes or end in -<number>":     "Namen von freebsd-Paketen dürfen keine Leerzeichen und Schrägstriche enthalten und nicht auf -<Zahl> enden",
+		"the epoch must be a non-negative number":                                          "die Epoche muss eine nicht negative Zahl sein",
+		"the iteration may only contain letters, digits and the characters . + ~":          "die Iteration darf nur Buchstaben, Ziffern und die Zeichen . + ~ enthalten",
+		"the architecture must be a single lowercase word like amd64, arm64 or all":        "die Architektur muss ein kleingeschriebenes Wort wie amd64, arm64 oder all sein",
+		"the file mode must be an octal number like 0755":                                  "der Dateimodus muss eine Oktalzahl wie 0755 sein",
+		"the install path must be absolute":                                                "der Installationspfad muss absolut sein",
+		"the install location must be absolute":                                            "das Installationsverzeichnis muss absolut sein",
+		"the prefix must be an absolute path":                                              "das Präfix muss ein absoluter Pfad sein",
+		"for mode dir it is required to specify a list of file paths (package.paths), a chdir (package.source.chdir) or sources (package.sources)": "Modus dir benötigt eine Liste von Pfaden (package.paths), ein chdir (package.source.chdir) oder Quellen (package.sources)",
+		"for mode deb exactly one path to a .deb file is required":                                                                                 "Modus deb benötigt genau einen Pfad zu einer .deb-Datei",
+		"for mode rpm exactly one path to a .rpm file is required":                                                                                 "Modus rpm benötigt genau einen Pfad zu einer .rpm-Datei",
+		"for mode tar exactly one path to a tarball (.tar, .tar.gz, .tgz, .tar.bz2, .tar.xz) is required":                                          "Modus tar benötigt genau einen Pfad zu einem Tarball (.tar, .tar.gz, .tgz, .tar.bz2, .tar.xz)",
+		"for mode gem exactly one path containing the name of the gem is required":                                                                 "Modus gem benötigt genau einen Pfad mit dem Namen des Gems",
+		"for mode python exactly one path to a setup.py or the name of a package is required":                                                      "Modus python benötigt genau einen Pfad zu einer setup.py oder den Namen eines Pakets",
+		"for mode virtualenv either a requirements file or exactly one path containing the name of a package is required":                          "Modus virtualenv benötigt eine requirements-Datei oder genau einen Pfad mit dem Namen eines Pakets",
+		"for mode git the url of the repository is required":                                                                                       "Modus git benötigt die URL des Repositorys",
+		"for mode url an https url is required":                                                                                                    "Modus url benötigt eine https-URL",
+		"for mode url the sha256 checksum of the download is required":                                                                             "Modus url benötigt die sha256-Prüfsumme des Downloads",
+		"for mode docker the image is required":                                                                                                    "Modus docker benötigt das Image",
+		"for mode go at least one main package is required":                                                                                        "Modus go benötigt mindestens ein main-Paket",
+		"for mode artifacts the artifacts directory as chdir and the manifest are required":                                                        "Modus artifacts benötigt das Verzeichnis der Artefakte als chdir und das Manifest",
+		"for mode pleaserun the first path has to be the absolute path of the program to run":                                                      "im Modus pleaserun muss der erste Pfad der absolute Pfad des Programms sein",
+		"metapackages of source mode empty contain no files, remove paths, excludes and chdir":                                                     "Metapakete des Quellmodus empty enthalten keine Dateien, entferne paths, excludes und chdir",
+		"sources are only available for source mode dir":                                                                                           "sources gibt es nur für den Quellmodus dir",
+		"sources replace paths and chdir, they can not be combined":                                                                                "sources ersetzen paths und chdir, sie können nicht kombiniert werden",
+		"every source needs exactly one of dir, url and content":                                                                                   "jede Quelle benötigt genau eines von dir, url und content",
+		"sources with url or content require a target path":                                                                                        "Quellen mit url oder content benötigen einen Zielpfad",
+		"the path policy is only available for source modes dir, git, url, docker, go and artifacts":                                               "die Pfadrichtlinie gibt es nur für die Quellmodi dir, git, url, docker, go und artifacts",
+		"the license audit is only available for source modes dir, git, url, docker, go and artifacts":                                             "die Lizenzprüfung gibt es nur für die Quellmodi dir, git, url, docker, go und artifacts",
+		"content rules are only available for source modes dir, git, url, docker, go and artifacts":                                                "Inhaltsregeln gibt es nur für die Quellmodi dir, git, url, docker, go und artifacts",
+		"shell integration is only available for source modes dir, git, url, docker, go and artifacts":                                             "die Shell-Integration gibt es nur für die Quellmodi dir, git, url, docker, go und artifacts",
+		"attributes are only available for source modes dir, git, url, docker, go and artifacts":                                                   "Attribute gibt es nur für die Quellmodi dir, git, url, docker, go und artifacts",
+		"manifest is only available for source mode artifacts":                                                                                     "manifest gibt es nur für den Quellmodus artifacts",
+		"image is only available for source mode docker":                                                                                           "image gibt es nur für den Quellmodus docker",
+		"preserve_ownership is only available for source mode tar":                                                                                 "preserve_ownership gibt es nur für den Quellmodus tar",
+		"ref and subdirectory are only available for source mode git":                                                                              "ref und subdirectory gibt es nur für den Quellmodus git",
+		"url is only available for source modes git and url, sha256 for source mode url":                                                           "url gibt es nur für die Quellmodi git und url, sha256 nur für den Quellmodus url",
+		"main, goos, goarch and install_path are only available for source mode go":                                                                "main, goos, goarch und install_path gibt es nur für den Quellmodus go",
+		"python is only available for source modes python and virtualenv":                                                                          "python gibt es nur für die Quellmodi python und virtualenv",
+		"pip, install_lib and install_bin are only available for source mode python":                                                               "pip, install_lib und install_bin gibt es nur für den Quellmodus python",
+		"requirements and install_location are only available for source mode virtualenv":                                                          "requirements und install_location gibt es nur für den Quellmodus virtualenv",
+		"service, user and working_dir are only available for source mode pleaserun":                                                               "service, user und working_dir gibt es nur für den Quellmodus pleaserun",
+		"compression is only available for target modes tar and deb":                                                                               "compression gibt es nur für die Zielmodi tar und deb",
+		"epoch is only available for target modes deb, rpm and pacman":                                                                             "epoch gibt es nur für die Zielmodi deb, rpm und pacman",
+		"summary is only available for target mode rpm":                                                                                            "summary gibt es nur für den Zielmodus rpm",
+		"dist is only available for target modes deb and rpm":                                                                                      "dist gibt es nur für die Zielmodi deb und rpm",
+		"the distribution must be a single suite name like jammy or bookworm":                                                                      "die Distribution muss ein einzelner Suite-Name wie jammy oder bookworm sein",
+		"output_dir is only available for target mode dir":                                                                                         "output_dir gibt es nur für den Zielmodus dir",
+		"post_extract is only available for target mode sh":                                                                                        "post_extract gibt es nur für den Zielmodus sh",
+		"optdepends are only available for target mode pacman":                                                                                     "optdepends gibt es nur für den Zielmodus pacman",
+		"user and group would replace the ownership preserved from the tarball":                                                                    "user und group würden die aus dem Tarball übernommenen Besitzer ersetzen",
+		"use a relative subdirectory of the repository instead of chdir":                                                                           "verwende ein relatives Unterverzeichnis des Repositorys statt chdir",
+		"package references are circular":                                                                                                          "die Verweise zwischen Paketen sind zirkulär",
+		"every configuration needs a unique name":                                                                                                  "jede Konfiguration benötigt einen eindeutigen Namen",
+		"the language may contain %s":                                                                                                              "die Sprache kann %s sein",
+
+		// configuration errors with values
+		"%s is no system account and is not created by %s (useradd, adduser, groupadd, addgroup or systemd-sysusers)": "%s ist kein Systemkonto und wird nicht von %s angelegt (useradd, adduser, groupadd, addgroup oder systemd-sysusers)",
+		"%q is not a package name, versions of provides must be exact like \"mta (= 1.0)\"":                           "%q ist kein Paketname, Versionen von provides müssen exakt sein wie \"mta (= 1.0)\"",
+		"%s (policy of %s)": "%s (Richtlinie von %s)",
+		"%s is created by fpm, use custom_control, config_files or the scripts instead": "%s wird von fpm erzeugt, verwende stattdessen custom_control, config_files oder die Skripte",
+		"%s packages may be compressed with %s":                                         "%s-Pakete können mit %s komprimiert werden",
+		"%s selects unknown package %s":                                                 "%s wählt das unbekannte Paket %s",
+		"completion %s is invalid: %s":                                                  "die Vervollständigung %s ist ungültig: %s",
+		"could not read the policy file: %s":                                            "die Richtliniendatei konnte nicht gelesen werden: %s",
+		"overrides may be read from %s":                                                 "Überschreibungen können aus %s gelesen werden",
+		"ownership can not be preserved for target mode %s, only for rpm|tar|dir":       "die Besitzer können für den Zielmodus %s nicht übernommen werden, nur für rpm|tar|dir",
+		"ownership may contain %s":                                                      "ownership kann %s sein",
+		"package names have to end with %q":                                             "Paketnamen müssen auf %q enden",
+		"package names have to start with %q":                                           "Paketnamen müssen mit %q beginnen",
+		"package names matching %q must not be built":                                   "Pakete mit Namen passend zu %q dürfen nicht gebaut werden",
+		"path %s leaves the source root, set allow_outside_paths if this is intended":   "der Pfad %s verlässt das Quellverzeichnis, setze allow_outside_paths, wenn das beabsichtigt ist",
+		"policy may contain %s":                                                         "die Richtlinie kann %s sein",
+		"profile snippet %s is invalid: %s":                                             "das Profil-Snippet %s ist ungültig: %s",
+		"publish type is required and may contain %s":                                   "ein Veröffentlichungstyp ist erforderlich und kann %s sein",
+		"release notes may be attached to %s":                                           "Release Notes können an %s angehängt werden",
+		"scanner is required and may contain %s":                                        "ein Scanner ist erforderlich und kann %s sein",
+		"script %s is invalid: %s":                                                      "das Skript %s ist ungültig: %s",
+		"severity may contain %s":                                                       "der Schweregrad kann %s sein",
+		"signer %s requires a key":                                                      "der Signierer %s benötigt einen Schlüssel",
+		"signer is required and may contain %s":                                         "ein Signierer ist erforderlich und kann %s sein",
+		"source mode is required and may contain %s":                                    "ein Quellmodus ist erforderlich und kann %s sein",
+		"target mode %s is listed more than once":                                       "der Zielmodus %s ist mehrfach angegeben",
+		"target mode is required and may contain %s":                                    "ein Zielmodus ist erforderlich und kann %s sein",
+		"the level of %s must be between %d and %d":                                     "die Stufe von %s muss zwischen %d und %d liegen",
+		"the log level may contain %s":                                                  "die Protokollstufe kann %s sein",
+		"the maintainer needs an email address of %s":                                   "der Maintainer benötigt eine E-Mail-Adresse von %s",
+		"the priority may contain %s":                                                   "die Priorität kann %s sein",
+		"the source offer %s does not exist or is empty":                                "das Quellcode-Angebot %s existiert nicht oder ist leer",
+		"the vendor has to be one of %s":                                                "der Hersteller muss einer von %s sein",
+		"triggers can not be combined with the meta file %s":                            "Trigger können nicht mit der Metadatei %s kombiniert werden",
+		"unknown timezone %s":                                                           "unbekannte Zeitzone %s",
+		"owners of single files are only available for target mode rpm":                 "Besitzer einzelner Dateien gibt es nur für den Zielmodus rpm",
+
+		// messages of the build
+		"building %s...\n":                    "baue %s...\n",
+		"cloning %s %s...\n":                  "klone %s %s...\n",
+		"downloading %s...\n":                 "lade %s herunter...\n",
+		"exporting %s...\n":                   "exportiere %s...\n",
+		"running %s hook: %s\n":               "führe %s-Hook aus: %s\n",
+		"building from commit %s\n":           "baue aus Commit %s\n",
+		"building all packages, %s changed\n": "baue alle Pakete, %s wurde geändert\n",
+		"::warning::building all packages, the changed files are unknown: %s\n":        "::warning::baue alle Pakete, die geänderten Dateien sind unbekannt: %s\n",
+		"skipping packages not affected by the pull request: %s\n":                     "überspringe Pakete, die der Pull Request nicht betrifft: %s\n",
+		"verified %d artifacts of %s against %s\n":                                     "%d Artefakte von %s gegen %s geprüft\n",
+		"verified the signature %s of %s\n":                                            "Signatur %s von %s geprüft\n",
+		"added changelog entry to %s %s\n":                                             "Changelog-Eintrag zu %s %s hinzugefügt\n",
+		"package %s contains %d GPL or LGPL components, adding the source offer %s\n":  "Paket %s enthält %d GPL- oder LGPL-Komponenten, füge das Quellcode-Angebot %s hinzu\n",
+		"package %s installs %d shared libraries, ldconfig runs after installing it\n": "Paket %s installiert %d Shared Libraries, ldconfig läuft nach der Installation\n",
+		"package %s: %s\n":                                               "Paket %s: %s\n",
+		"warning: %s\n":                                                  "Warnung: %s\n",
+		"%s package %s differs from %s:\n":                               "%s-Paket %s weicht von %s ab:\n",
+		"  the order of the arguments changed\n":                         "  die Reihenfolge der Argumente hat sich geändert\n",
+		"wrote %s\n":                                                     "%s geschrieben\n",
+		"writing %s\n":                                                   "schreibe %s\n",
+		"saved %d files to the cache as %s\n":                            "%d Dateien im Cache als %s gespeichert\n",
+		"restored %d packages from the cache %s\n":                       "%d Pakete aus dem Cache %s wiederhergestellt\n",
+		"could not clone %s: %s\n":                                       "%s konnte nicht geklont werden: %s\n",
+		"could not export %s: %s\n":                                      "%s konnte nicht exportiert werden: %s\n",
+		"could not build %s: %s\n":                                       "%s konnte nicht gebaut werden: %s\n",
+		"could not download %s: %s\n":                                    "%s konnte nicht heruntergeladen werden: %s\n",
+		"artifacts of %s do not match %s: %s\n":                          "die Artefakte von %s stimmen nicht mit %s überein: %s\n",
+		"could not extract %s: %s\n":                                     "%s konnte nicht entpackt werden: %s\n",
+		"could not stage sources of %s: %s\n":                            "die Quellen von %s konnten nicht bereitgestellt werden: %s\n",
+		"invalid symlink in package %s: %s\n":                            "ungültiger Symlink in Paket %s: %s\n",
+		"invalid file name in package %s: %s\n":                          "ungültiger Dateiname in Paket %s: %s\n",
+		"could not add ldconfig to package %s: %s\n":                     "ldconfig konnte nicht zu Paket %s hinzugefügt werden: %s\n",
+		"could not check the systemd units of package %s: %s\n":          "die systemd-Units von Paket %s konnten nicht geprüft werden: %s\n",
+		"could not run the license audit of package %s: %s\n":            "die Lizenzprüfung von Paket %s konnte nicht ausgeführt werden: %s\n",
+		"could not add the shell integration of package %s: %s\n":        "die Shell-Integration von Paket %s konnte nicht hinzugefügt werden: %s\n",
+		"could not apply the attributes of package %s: %s\n":             "die Attribute von Paket %s konnten nicht gesetzt werden: %s\n",
+		"could not restore the mode of %s: %s\n":                         "der Dateimodus von %s konnte nicht wiederhergestellt werden: %s\n",
+		"could not create the directory of %s: %s\n":                     "das Verzeichnis von %s konnte nicht angelegt werden: %s\n",
+		"invalid apk_key of %s: %s\n":                                    "ungültiger apk_key von %s: %s\n",
+		"could not finish apk package %s: %s\n":                          "apk-Paket %s konnte nicht fertiggestellt werden: %s\n",
+		"could not sign %s: %s\n":                                        "%s konnte nicht signiert werden: %s\n",
+		"vulnerability scan of %s failed: %s\n":                          "die Schwachstellenprüfung von %s ist fehlgeschlagen: %s\n",
+		"drift check of %s failed: %s\n":                                 "die Drift-Prüfung von %s ist fehlgeschlagen: %s\n",
+		"could not redact output: %s\n":                                  "die Ausgabe konnte nicht geschwärzt werden: %s\n",
+		"could not create keyring package: %s\n":                         "das Keyring-Paket konnte nicht erstellt werden: %s\n",
+		"could not append changelog entry: %s\n":                         "der Changelog-Eintrag konnte nicht angefügt werden: %s\n",
+		"command restore requires the key cache in packages.yml\n":       "der Befehl restore benötigt den Schlüssel cache in packages.yml\n",
+		"could not restore the packages from the cache: %v\n":            "die Pakete konnten nicht aus dem Cache wiederhergestellt werden: %v\n",
+		"::warning::could not restore the packages from the cache: %s\n": "::warning::die Pakete konnten nicht aus dem Cache wiederhergestellt werden: %s\n",
+		"::warning::could not save the packages to the cache: %s\n":      "::warning::die Pakete konnten nicht im Cache gespeichert werden: %s\n",
+		"could not write report: %s\n":                                   "der Bericht konnte nicht geschrieben werden: %s\n",
+		"promotion failed: %s\n":                                         "die Freigabe ist fehlgeschlagen: %s\n",
+		"could not record the build environment: %s\n":                   "die Build-Umgebung konnte nicht aufgezeichnet werden: %s\n",
+		"could not start the terminal view: %s\n":                        "die Terminalansicht konnte nicht gestartet werden: %s\n",
+		"could not write %s: %s\n":                                       "%s konnte nicht geschrieben werden: %s\n",
+		"could not create release notes: %s\n":                           "die Release Notes konnten nicht erstellt werden: %s\n",
+		"could not write the expiry index: %s\n":                         "der Ablaufindex konnte nicht geschrieben werden: %s\n",
+		"could not sign release files: %s\n":                             "die Release-Dateien konnten nicht signiert werden: %s\n",
+	},
+}
+
+// function tr translates a message or format string to the selected language
+func tr(message string) string {
+	if translated, ok := catalogs[language][message]; ok {
+		return translated
+	}
+	return message
+}
+
+// function languages lists the languages messages are available in
+func languages() []string {
+	names := []string{}
+	for name := range catalogs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// function languageOf returns the language of a locale like de_DE.UTF-8
+func languageOf(locale string) string {
+	if i := strings.IndexAny(locale, "_.@"); i >= 0 {
+		locale = locale[:i]
+	}
+	return strings.ToLower(locale)
+}
+
+// function environmentLanguage returns the language of the locale of the user, English if it has no catalog
+// the variables are read before LC_ALL is set for the commands of the run
+func environmentLanguage() string {
+	for _, name := range []string{"LANGUAGE", "LC_ALL", "LC_MESSAGES", "LANG"} {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		// LANGUAGE is a list of preferred languages
+		for _, locale := range strings.Split(value, ":") {
+			if _, ok := catalogs[languageOf(locale)]; ok {
+				return languageOf(locale)
+			}
+		}
+		return "en"
+	}
+	return "en"
+}
+
+// function selectLanguage selects the language of the messages, field names the option it was configured with
+func selectLanguage(selected, field string) error {
+	if _, ok := catalogs[selected]; !ok {
+		return ConfigError{
+			field:   field,
+			message: fmt.Sprintf(tr("the language may contain %s"), strings.Join(languages(), "|")),
+		}
+	}
+	language = selected
+	return nil
+}
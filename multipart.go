@@ -0,0 +1,191 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// attempts to upload a single part before the upload fails
+const partAttempts = 3
+
+// uploadedPart is a part of a multipart upload as listed and completed by the aws cli
+type uploadedPart struct {
+	PartNumber int    `json:"PartNumber"`
+	ETag       string `json:"ETag"`
+}
+
+// function awsJSON runs the aws cli with json output and decodes it into v
+func awsJSON(v interface{}, args ...string) error {
+	cmd := exec.Command("aws", append(args, "--output", "json")...)
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("aws %s failed: %s", strings.Join(args, " "), err)
+	}
+	if v == nil || len(strings.TrimSpace(string(output))) == 0 {
+		return nil
+	}
+	return json.Unmarshal(output, v)
+}
+
+// function splitS3URL splits s3://bucket/key into bucket and key
+func splitS3URL(url string) (string, string) {
+	parts := strings.SplitN(strings.TrimPrefix(url, "s3://"), "/", 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+// function readPart reads part n (counted from 1) of a file into a temporary file
+// it returns the path of the temporary file and the md5 digest of the part
+func readPart(f *os.File, n int, size int64) (string, []byte, error) {
+	part, err := ioutil.TempFile("", "part-")
+	if err != nil {
+		return "", nil, err
+	}
+	defer part.Close()
+	h := md5.New()
+	section := io.NewSectionReader(f, int64(n-1)*size, size)
+	if _, err := io.Copy(io.MultiWriter(part, h), section); err != nil {
+		os.Remove(part.Name())
+		return "", nil, err
+	}
+	return part.Name(), h.Sum(nil), nil
+}
+
+// function resumableUpload returns the id of an unfinished multipart upload of the key, if there is one
+func resumableUpload(bucket, key string) (string, error) {
+	uploads := struct {
+		Uploads []struct {
+			Key       string `json:"Key"`
+			UploadID  string `json:"UploadId"`
+			Initiated string `json:"Initiated"`
+		} `json:"Uploads"`
+	}{}
+	if err := awsJSON(&uploads, "s3api", "list-multipart-uploads", "--bucket", bucket, "--prefix", key); err != nil {
+		return "", err
+	}
+	id, initiated := "", ""
+	for _, u := range uploads.Uploads {
+		if u.Key == key && u.Initiated > initiated {
+			id, initiated = u.UploadID, u.Initiated
+		}
+	}
+	return id, nil
+}
+
+// function multipartUpload uploads a file to url in parts of chunkSize MiB
+//
+// an unfinished upload of the same key, e.g. of a run that lost its connection, is resumed. parts that were
+// uploaded before are kept if their ETag matches the md5 digest of the local part. S3 verifies every part
+// against its Content-MD5 and the ETag of the completed object is compared with the digest of all parts
+func multipartUpload(file, url string, chunkSize int) error {
+	bucket, key := splitS3URL(url)
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	size := int64(chunkSize) << 20
+	count := int((info.Size() + size - 1) / size)
+	if count == 0 {
+		count = 1
+	}
+
+	id, err := resumableUpload(bucket, key)
+	if err != nil {
+		return err
+	}
+	uploaded := map[int]string{}
+	if id != "" {
+		listed := struct {
+			Parts []uploadedPart `json:"Parts"`
+		}{}
+		if err := awsJSON(&listed, "s3api", "list-parts", "--bucket", bucket, "--key", key, "--upload-id", id); err != nil {
+			return err
+		}
+		for _, p := range listed.Parts {
+			uploaded[p.PartNumber] = strings.Trim(p.ETag, `"`)
+		}
+		fmt.Printf("resuming the upload of %s, %d of %d parts were uploaded before\n", url, len(uploaded), count)
+	} else {
+		created := struct {
+			UploadID string `json:"UploadId"`
+		}{}
+		if err := awsJSON(&created, "s3api", "create-multipart-upload", "--bucket", bucket, "--key", key); err != nil {
+			return err
+		}
+		id = created.UploadID
+	}
+
+	parts := []uploadedPart{}
+	digests := []byte{}
+	for n := 1; n <= count; n++ {
+		path, digest, err := readPart(f, n, size)
+		if err != nil {
+			return err
+		}
+		digests = append(digests, digest...)
+		etag := hex.EncodeToString(digest)
+
+		if uploaded[n] != etag {
+			for attempt := 1; ; attempt++ {
+				result := struct {
+					ETag string `json:"ETag"`
+				}{}
+				err = awsJSON(&result, "s3api", "upload-part", "--bucket", bucket, "--key", key,
+					"--upload-id", id, "--part-number", fmt.Sprint(n), "--body", path,
+					"--content-md5", base64.StdEncoding.EncodeToString(digest))
+				if err == nil && strings.Trim(result.ETag, `"`) != etag {
+					err = fmt.Errorf("part %d was stored with ETag %s, expected %s", n, result.ETag, etag)
+				}
+				if err == nil || attempt == partAttempts {
+					break
+				}
+				fmt.Printf("upload of part %d of %s failed, retrying: %s\n", n, url, err)
+			}
+		}
+		os.Remove(path)
+		if err != nil {
+			return fmt.Errorf("upload of part %d of %s failed, run again to resume: %s", n, url, err)
+		}
+		parts = append(parts, uploadedPart{PartNumber: n, ETag: `"` + etag + `"`})
+	}
+
+	completion, err := json.Marshal(map[string][]uploadedPart{"Parts": parts})
+	if err != nil {
+		return err
+	}
+	if err := awsJSON(nil, "s3api", "complete-multipart-upload", "--bucket", bucket, "--key", key,
+		"--upload-id", id, "--multipart-upload", string(completion)); err != nil {
+		return err
+	}
+
+	// the ETag of a multipart object is the md5 digest of the digests of all parts and the number of parts
+	sum := md5.Sum(digests)
+	expected := fmt.Sprintf("%s-%d", hex.EncodeToString(sum[:]), count)
+	head := struct {
+		ETag string `json:"ETag"`
+	}{}
+	if err := awsJSON(&head, "s3api", "head-object", "--bucket", bucket, "--key", key); err != nil {
+		return err
+	}
+	if strings.Trim(head.ETag, `"`) != expected {
+		return fmt.Errorf("%s was stored with ETag %s, expected %s", url, head.ETag, expected)
+	}
+	fmt.Printf("uploaded %s in %d parts\n", url, count)
+	return nil
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ReleaseNotes configures the generation of release notes for all packages
+type ReleaseNotes struct {
+	// Manifests is the directory the manifests of this build are written to
+	// defaults to "manifests"
+	Manifests string `yaml:"manifests"`
+
+	// Previous is a directory containing the manifests of the previous release *OPTIONAL*
+	// without it file and dependency changes can not be computed
+	Previous string `yaml:"previous"`
+
+	// Changelog is a markdown file an excerpt for the current version is taken from *OPTIONAL*
+	Changelog string `yaml:"changelog"`
+
+	// Output is the markdown file the release notes are written to *OPTIONAL*
+	Output string `yaml:"output"`
+
+	// Attach lists where the release notes are published to
+	//
+	// "summary": append the notes to the job summary of the workflow run
+	// "release": append the notes to the body of the GitHub release that triggered the workflow
+	Attach []string `yaml:"attach"`
+}
+
+// Manifest records what a built package contained so later releases can be compared against it
+type Manifest struct {
+	Name    string         `json:"name"`
+	Version string         `json:"version"`
+	Depends []string       `json:"depends"`
+	Files   []ManifestFile `json:"files"`
+}
+
+// ManifestFile is a single file entry of a Manifest
+type ManifestFile struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// method manifestDir returns the directory the manifests of this build are written to
+func (r *ReleaseNotes) manifestDir() string {
+	if r.Manifests == "" {
+		return "manifests"
+	}
+	return r.Manifests
+}
+
+// method manifest collects the Manifest of a package from its configuration and contents
+func (p *Package) manifest() (Manifest, error) {
+	m := Manifest{
+		Name:    p.Name,
+		Version: p.Target.Version,
+		Depends: p.Target.Depends,
+		Files:   []ManifestFile{},
+	}
+
+	contents, err := p.contents()
+	if err != nil {
+		return m, err
+	}
+	for _, f := range contents {
+		m.Files = append(m.Files, ManifestFile{Path: f.Target, Size: f.Info.Size()})
+	}
+	return m, nil
+}
+
+// function readManifest reads the manifest of the named package from dir
+// a missing manifest is not an error, in that case ok is false
+func readManifest(dir, name string) (m Manifest, ok bool, err error) {
+	contents, err := ioutil.ReadFile(filepath.Join(dir, name+".json"))
+	if os.IsNotExist(err) {
+		return m, false, nil
+	}
+	if err != nil {
+		return m, false, err
+	}
+	if err := json.Unmarshal(contents, &m); err != nil {
+		return m, false, err
+	}
+	return m, true, nil
+}
+
+// function writeManifest stores a manifest as <dir>/<name>.json
+func writeManifest(dir string, m Manifest) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	contents, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, m.Name+".json"), contents, 0644)
+}
+
+// function changelogExcerpt returns the section of a markdown changelog whose heading mentions version
+// the section ends at the next heading of the same or a higher level
+func changelogExcerpt(path, version string) (string, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	var excerpt []string
+	level := 0
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			l := len(line) - len(strings.TrimLeft(line, "#"))
+			if level > 0 && l <= level {
+				break
+			}
+			if level == 0 && strings.Contains(line, version) {
+				level = l
+				continue
+			}
+		}
+		if level > 0 {
+			excerpt = append(excerpt, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(excerpt, "\n")), scanner.Err()
+}
+
+// function diffList compares two string lists and returns the entries only present in one of them
+func diffList(previous, current []string) (added, removed []string) {
+	for _, c := range current {
+		if !contains(previous, c) {
+			added = append(added, c)
+		}
+	}
+	for _, p := range previous {
+		if !contains(current, p) {
+			removed = append(removed, p)
+		}
+	}
+	return added, removed
+}
+
+// function writeList renders a markdown list of entries prefixed by sign
+// long lists are cut off to keep the notes readable
+func writeList(b *strings.Builder, sign string, entries []string) {
+	const limit = 50
+	for i, e := range entries {
+		if i == limit {
+			fmt.Fprintf(b, "- ... and %d more\n", len(entries)-limit)
+			break
+		}
+		fmt.Fprintf(b, "- %s `%s`\n", sign, e)
+	}
+}
+
+// function packageNotes renders the markdown release notes of a single package
+func packageNotes(r *ReleaseNotes, m Manifest, previous *Manifest) (string, error) {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "## %s %s\n\n", m.Name, m.Version)
+
+	if r.Changelog != "" {
+		excerpt, err := changelogExcerpt(r.Changelog, m.Version)
+		if err != nil {
+			return "", err
+		}
+		if excerpt != "" {
+			fmt.Fprintf(b, "%s\n\n", excerpt)
+		}
+	}
+
+	if previous == nil {
+		fmt.Fprintf(b, "_no previous release to compare against_\n\n")
+		return b.String(), nil
+	}
+
+	fmt.Fprintf(b, "Changes since %s:\n\n", previous.Version)
+
+	// compare file lists including sizes
+	previousFiles := map[string]int64{}
+	var previousPaths, currentPaths, changed []string
+	for _, f := range previous.Files {
+		previousFiles[f.Path] = f.Size
+		previousPaths = append(previousPaths, f.Path)
+	}
+	for _, f := range m.Files {
+		currentPaths = append(currentPaths, f.Path)
+		if size, ok := previousFiles[f.Path]; ok && size != f.Size {
+			changed = append(changed, f.Path)
+		}
+	}
+	addedFiles, removedFiles := diffList(previousPaths, currentPaths)
+
+	fmt.Fprintf(b, "### Files\n\n")
+	if len(addedFiles)+len(removedFiles)+len(changed) == 0 {
+		fmt.Fprintf(b, "no changes\n")
+	}
+	writeList(b, "+", addedFiles)
+	writeList(b, "-", removedFiles)
+	writeList(b, "~", changed)
+
+	addedDepends, removedDepends := diffList(previous.Depends, m.Depends)
+	fmt.Fprintf(b, "\n### Dependencies\n\n")
+	if len(addedDepends)+len(removedDepends) == 0 {
+		fmt.Fprintf(b, "no changes\n")
+	}
+	writeList(b, "+", addedDepends)
+	writeList(b, "-", removedDepends)
+
+	return b.String() + "\n", nil
+}
+
+// function appendJobSummary adds markdown to the job summary of the current workflow run
+func appendJobSummary(markdown string) error {
+	path := os.Getenv("GITHUB_STEP_SUMMARY")
+	if path == "" {
+		return fmt.Errorf("GITHUB_STEP_SUMMARY is not set, not running in GitHub Actions?")
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.WriteString(markdown)
+	return err
+}
+
+// function appendReleaseBody adds markdown to the body of the release the workflow was triggered by
+func appendReleaseBody(markdown string) error {
+	event := struct {
+		Release *struct {
+			ID   int64  `json:"id"`
+			Body string `json:"body"`
+		} `json:"release"`
+	}{}
+
+	contents, err := ioutil.ReadFile(os.Getenv("GITHUB_EVENT_PATH"))
+	if err != nil {
+		return fmt.Errorf("could not read GitHub event: %s", err)
+	}
+	if err := json.Unmarshal(contents, &event); err != nil {
+		return err
+	}
+	if event.Release == nil {
+		return fmt.Errorf("workflow was not triggered by a release")
+	}
+
+	body := map[string]string{"body": event.Release.Body + "\n\n" + markdown}
+	path := fmt.Sprintf("/repos/%s/releases/%d", os.Getenv("GITHUB_REPOSITORY"), event.Release.ID)
+	return newGitHubClient().request(http.MethodPatch, path, body, nil)
+}
+
+// method renderNotes writes the manifests of all packages and renders the release notes below the heading title
+func (c *FPMConfig) renderNotes(title string) (string, error) {
+	r := c.ReleaseNotes
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "# %s\n\n", title)
+
+	written := []string{}
+	for _, p := range c.Packages {
+		// entries expanded from multiple target modes share their contents
+		if contains(written, p.Name) {
+			continue
+		}
+		written = append(written, p.Name)
+
+		m, err := p.manifest()
+		if err != nil {
+			return "", err
+		}
+		if err := writeManifest(r.manifestDir(), m); err != nil {
+			return "", err
+		}
+
+		var previous *Manifest
+		if r.Previous != "" {
+			pm, ok, err := readManifest(r.Previous, p.Name)
+			if err != nil {
+				return "", err
+			}
+			if ok {
+				previous = &pm
+			}
+		}
+
+		notes, err := packageNotes(r, m, previous)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(notes)
+	}
+	return redaction.redact(b.String()), nil
+}
+
+// method releaseNotes writes the manifests of all packages, renders the release notes and attaches them
+func (c *FPMConfig) releaseNotes() error {
+	notes, err := c.renderNotes("Packages")
+	if err != nil {
+		return err
+	}
+	return c.ReleaseNotes.publishNotes(notes)
+}
+
+// method publishNotes writes the release notes to the output file and attaches them
+func (r *ReleaseNotes) publishNotes(notes string) error {
+	if r.Output != "" {
+		if err := ioutil.WriteFile(r.Output, []byte(notes), 0644); err != nil {
+			return err
+		}
+	}
+	if contains(r.Attach, "summary") {
+		if err := appendJobSummary(notes); err != nil {
+			return err
+		}
+	}
+	if contains(r.Attach, "release") {
+		if err := appendReleaseBody(notes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
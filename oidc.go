@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// default audience of the token exchanged with AWS STS
+const defaultOIDCAudience = "sts.amazonaws.com"
+
+// OIDC exchanges the OIDC token of the workflow for short-lived credentials of a cloud role
+// the workflow needs the permission "id-token: write", no long-lived secrets are stored in the repository
+type OIDC struct {
+	// Role is the ARN of the AWS role assumed with the token *REQUIRED*
+	Role string `yaml:"role"`
+
+	// Audience of the token as trusted by the identity provider of the role, defaults to sts.amazonaws.com *OPTIONAL*
+	Audience string `yaml:"audience"`
+
+	// Region of the AWS STS endpoint and the bucket, defaults to the region configured for the aws cli *OPTIONAL*
+	Region string `yaml:"region"`
+
+	// Duration the credentials are valid in seconds, between 900 and 43200, defaults to 3600 *OPTIONAL*
+	Duration int `yaml:"duration"`
+
+	// env holds the credentials of the last exchange until they expire
+	env     map[string]string
+	expires time.Time
+}
+
+// method check validates the role and duration
+func (o *OIDC) check(field string) error {
+	if !strings.HasPrefix(o.Role, "arn:") {
+		return ConfigError{
+			field:   field + ".role",
+			message: "the ARN of the role to assume is required e.g. arn:aws:iam::123456789012:role/publish",
+		}
+	}
+	if o.Duration != 0 && (o.Duration < 900 || o.Duration > 43200) {
+		return ConfigError{
+			field:   field + ".duration",
+			message: "AWS issues credentials for 900 to 43200 seconds",
+		}
+	}
+	return nil
+}
+
+// function requestIDToken requests an OIDC token of the workflow run for an audience from GitHub
+func requestIDToken(audience string) (string, error) {
+	endpoint := os.Getenv("ACTIONS_ID_TOKEN_REQUEST_URL")
+	bearer := os.Getenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN")
+	if endpoint == "" || bearer == "" {
+		return "", fmt.Errorf("no OIDC token available, the workflow needs the permission id-token: write")
+	}
+
+	req, err := http.NewRequest("GET", endpoint+"&audience="+url.QueryEscape(audience), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "bearer "+bearer)
+	resp, err := (&http.Client{Timeout: 30 * time.Second}).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("requesting the OIDC token failed: %s", resp.Status)
+	}
+
+	token := struct {
+		Value string `json:"value"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	return token.Value, nil
+}
+
+// method credentials returns the environment of the aws cli with credentials of the role
+// the token is exchanged again once the credentials expire within the next five minutes
+func (o *OIDC) credentials() (map[string]string, error) {
+	if o.env != nil && time.Until(o.expires) > 5*time.Minute {
+		return o.env, nil
+	}
+
+	audience := o.Audience
+	if audience == "" {
+		audience = defaultOIDCAudience
+	}
+	token, err := requestIDToken(audience)
+	if err != nil {
+		return nil, err
+	}
+	redaction.add(token)
+
+	duration := o.Duration
+	if duration == 0 {
+		duration = 3600
+	}
+	session := "action-package"
+	if id := os.Getenv("GITHUB_RUN_ID"); id != "" {
+		session += "-" + id
+	}
+	args := []string{"sts", "assume-role-with-web-identity", "--role-arn", o.Role, "--role-session-name", session,
+		"--web-identity-token", token, "--duration-seconds", strconv.Itoa(duration)}
+	if o.Region != "" {
+		args = append(args, "--region", o.Region)
+	}
+	result := struct {
+		Credentials struct {
+			AccessKeyID     string `json:"AccessKeyId"`
+			SecretAccessKey string `json:"SecretAccessKey"`
+			SessionToken    string `json:"SessionToken"`
+			Expiration      string `json:"Expiration"`
+		} `json:"Credentials"`
+	}{}
+	if err := awsJSON(&result, args...); err != nil {
+		return nil, err
+	}
+
+	credentials := result.Credentials
+	for _, secret := range []string{credentials.AccessKeyID, credentials.SecretAccessKey, credentials.SessionToken} {
+		redaction.add(secret)
+	}
+	o.expires, err = time.Parse(time.RFC3339, credentials.Expiration)
+	if err != nil {
+		o.expires = time.Now().Add(time.Duration(duration) * time.Second)
+	}
+	o.env = map[string]string{
+		"AWS_ACCESS_KEY_ID":     credentials.AccessKeyID,
+		"AWS_SECRET_ACCESS_KEY": credentials.SecretAccessKey,
+		"AWS_SESSION_TOKEN":     credentials.SessionToken,
+	}
+	if o.Region != "" {
+		o.env["AWS_REGION"] = o.Region
+		o.env["AWS_DEFAULT_REGION"] = o.Region
+	}
+	fmt.Printf("assumed role %s until %s\n", o.Role, o.expires.Format(time.RFC3339))
+	return o.env, nil
+}
+
+// method withCredentials runs f with the short-lived credentials of the target in the environment
+// the previous environment is restored afterwards, so other targets keep their own credentials
+func (t *PublishTarget) withCredentials(f func() error) error {
+	if t.OIDC == nil {
+		return f()
+	}
+	env, err := t.OIDC.credentials()
+	if err != nil {
+		return fmt.Errorf("could not exchange the OIDC token of %s: %s", t.name(), err)
+	}
+
+	for name, value := range env {
+		previous, set := os.LookupEnv(name)
+		os.Setenv(name, value)
+		if set {
+			defer os.Setenv(name, previous)
+		} else {
+			defer os.Unsetenv(name)
+		}
+	}
+	return f()
+}
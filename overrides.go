@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// trailers like "Package-Channel: beta" in git messages override the configuration
+var trailerPattern = regexp.MustCompile(`^Package-(Version|Channel|Packages):\s*(.+)$`)
+
+// GitOverrides reads per release overrides from git, so releases can be influenced without editing packages.yml
+//
+// recognized trailers are "Package-Version", "Package-Channel" (the distribution of all publish targets)
+// and "Package-Packages" (a comma or space separated list of packages to build)
+type GitOverrides struct {
+	// From lists where trailers are read from, later sources take precedence *REQUIRED*
+	//
+	// "tag": the annotation of the tag pointing to the current commit
+	// "commit": the message of the current commit
+	// "notes": the git notes of the current commit
+	From []string `yaml:"from"`
+}
+
+// method check validates the git overrides configuration
+func (g *GitOverrides) check() error {
+	validSources := []string{"tag", "commit", "notes"}
+	for _, f := range g.From {
+		if !contains(validSources, f) {
+			return ConfigError{
+				field:   "git_overrides.from",
+				message: fmt.Sprintf(tr("overrides may be read from %s"), strings.Join(validSources, "|")),
+			}
+		}
+	}
+	return nil
+}
+
+// function gitMessage returns the message of a source of overrides, missing tags or notes result in an empty message
+func gitMessage(from string) string {
+	var cmd *exec.Cmd
+	switch from {
+	case "tag":
+		cmd = exec.Command("git", "for-each-ref", "--points-at", "HEAD", "--format=%(contents)", "refs/tags")
+	case "commit":
+		cmd = exec.Command("git", "log", "-1", "--format=%B", "HEAD")
+	case "notes":
+		cmd = exec.Command("git", "notes", "show", "HEAD")
+	}
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return string(output)
+}
+
+// method trailers collects the override trailers of all sources
+func (g *GitOverrides) trailers() map[string]string {
+	trailers := map[string]string{}
+	for _, f := range g.From {
+		scanner := bufio.NewScanner(strings.NewReader(gitMessage(f)))
+		for scanner.Scan() {
+			if m := trailerPattern.FindStringSubmatch(strings.TrimSpace(scanner.Text())); m != nil {
+				trailers[m[1]] = strings.TrimSpace(m[2])
+			}
+		}
+	}
+	return trailers
+}
+
+// method applyGitOverrides applies the trailers found in git to the configuration
+func (c *FPMConfig) applyGitOverrides() error {
+	g := c.GitOverrides
+	if g == nil {
+		return nil
+	}
+	if err := g.check(); err != nil {
+		return err
+	}
+
+	t := g.trailers()
+	for _, k := range []string{"Version", "Channel", "Packages"} {
+		if t[k] != "" {
+			fmt.Printf("git override Package-%s: %s\n", k, t[k])
+		}
+	}
+	return c.override(t["Version"], t["Channel"], t["Packages"], "git_overrides.from", "trailer Package-Packages")
+}
@@ -1,99 +1,615 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"gopkg.in/yaml.v2"
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 )
 
+// freebsd package names must not end in something pkg would take for a version
+var freebsdVersionSuffix = regexp.MustCompile(`-[0-9][^-]*$`)
+
+// iterations are debian revisions, they must not contain hyphens
+var iterationPattern = regexp.MustCompile(`^[A-Za-z0-9.+~]+$`)
+
+// architectures are single words like amd64, arm64 or all
+var architecturePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// names of control fields, they must not contain spaces or colons
+var fieldNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]*$`)
+
+// provided names are virtual package names, optionally with an exact version like "mta (= 1.0)"
+var providesPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9.+_-]*( \(?= ?[^ ()]+\)?)?$`)
+
+// distributions of debian changelogs are suite names like jammy or bookworm-backports
+var distributionPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9.+_-]*$`)
+
+// sha256 checksums in hex
+var sha256Pattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// tarballs packaged by source mode tar
+var tarballPattern = regexp.MustCompile(`\.(tar|tar\.gz|tgz|tar\.bz2|tar\.xz)$`)
+
+// macOS package identifiers use reverse domain notation
+var osxIdentifierPattern = regexp.MustCompile(`^[A-Za-z0-9-]+(\.[A-Za-z0-9-]+)+$`)
+
 // fomConfig contains all configuration needed to create a package using fpm
 type FPMConfig struct {
-	Packages []struct {
+	Packages []Package
 
-		// the name of the target package
-		Name string
+	// ReleaseNotes enables the generation of release notes *OPTIONAL*
+	ReleaseNotes *ReleaseNotes `yaml:"release_notes"`
 
-		// section Source of the fpm config
-		// defines where and how to source the contents of the package
-		Source struct {
-			// source mode specifies how to gather the files contained in the package
-			//
-			// "dir":
-			// use mode dir to source files from a local directory
-			// a valid configuration using "dir" needs at least one argument containing a path
-			//
-			// Mode is REQUIRED
-			Mode string `yaml:"mode"`
+	// Scan enables a vulnerability scan of every built package *OPTIONAL*
+	Scan *Scan `yaml:"scan"`
 
-			// Excludes is used with mode "dir"
-			// paths to files that are explicitly not part of the packages source files
-			Excludes []string `yaml:"excludes"`
+	// Expiry marks the packages as outdated some days after the build, e.g. for nightly channels *OPTIONAL*
+	Expiry *Expiry `yaml:"expiry"`
 
-			Chdir string `yaml:"chdir"`
-		} `yaml:"source"`
+	// Drift warns about packages growing considerably compared to the previous release *OPTIONAL*
+	Drift *Drift `yaml:"drift"`
 
-		// section Target of the fpm config
-		Target struct {
-			// Mode specifies the kind of package to create *REQUIRED*
-			//
-			// "deb":
-			// use mode "deb" to create a debian package
-			// a valid configuration using "deb" needs flags "name"
-			Mode string `yaml:"mode"`
+	// Signing configures how packages are signed *OPTIONAL*
+	Signing *Signing `yaml:"signing"`
 
+	// Publish configures where built packages are published to *OPTIONAL*
+	Publish PublishTargets `yaml:"publish"`
 
-			// package Version *REQUIRED*
-			Version string `yaml:"version"`
+	// Dispatch maps workflow_dispatch inputs onto the configuration *OPTIONAL*
+	Dispatch *Dispatch `yaml:"dispatch"`
 
-			// package architecture - defaults to local architecture of whatever machine is building the package
-			Architecture string `yaml:"architecture"`
+	// GitOverrides reads overrides from trailers of git tags, commits or notes *OPTIONAL*
+	// inputs of dispatch take precedence
+	GitOverrides *GitOverrides `yaml:"git_overrides"`
 
-			// Maintainer of the package *OPTIONAL*
-			// should be an email address
-			Maintainer string `yaml:"maintainer"`
+	// Report is the path a json report of the run is written to *OPTIONAL*
+	Report string `yaml:"report"`
 
-			// Vendor of the package *OPTIONAL*
-			Vendor string `yaml:"vendor"`
+	// Timezone used for all timestamps of the run, defaults to "UTC" *OPTIONAL*
+	Timezone string `yaml:"timezone"`
 
-			// project URL *OPTIONAL*
-			// will be displayed in the packages metadata alongside the description
-			URL string `yaml:"url"`
-			License string `yaml:"license"`
-			Description string `yaml:"description"`
+	// Locale used by the action and all commands it runs, defaults to "C" *OPTIONAL*
+	Locale string `yaml:"locale"`
 
-			Provides []string `yaml:"provides"`
+	// Language of the messages of the action: en|de, defaults to the language of the locale of the user *OPTIONAL*
+	// it is independent of the locale of the commands
+	Language string `yaml:"language"`
 
-			// special file tags
-			Directories []string `yaml:"directories"`
-			ConfigFiles []string `yaml:"config_files"`
-			Systemd     []string `yaml:"systemd"`
+	// EnvironmentFields adds the build environment as control fields to deb packages *OPTIONAL*
+	// the environment is always recorded in the report
+	EnvironmentFields bool `yaml:"environment_fields"`
 
-			// dependency management
-			Depends       []string `yaml:"depends"`
-			Suggests      []string `yaml:"suggests"`
-			NoAutoDepends bool     `yaml:"no_auto_depends"`
-			Conflicts     []string `yaml:"conflicts"`
+	// Selective builds only the packages affected by the changes of a pull request *OPTIONAL*
+	Selective *Selective `yaml:"selective"`
 
-			// script tags
-			BeforeInstall string `yaml:"before_install"`
-			AfterInstall  string `yaml:"after_install"`
+	// Cache keeps the built packages in the Actions cache for later jobs of the workflow *OPTIONAL*
+	Cache *Cache `yaml:"cache"`
 
-			BeforeRemove string `yaml:"before_remove"`
-			AfterRemove  string `yaml:"after_remove"`
+	// Redact lists environment variables whose values are masked in all output *OPTIONAL*
+	// credentials of publish targets, apk keys and well known token variables are always masked
+	Redact []string `yaml:"redact"`
 
-			BeforeUpgrade string `yaml:"before_upgrade"`
-			AfterUpgrade  string `yaml:"after_upgrade"`
+	// RequireCleanTree refuses to build if the workspace has uncommitted changes *OPTIONAL*
+	// or is not at the commit GITHUB_SHA that triggered the workflow
+	RequireCleanTree bool `yaml:"require_clean_tree"`
 
-			SystemdEnable              bool `yaml:"systemd_enable"`
-			SystemdAutoStart           bool `yaml:"systemd_auto_start"`
-			SystemdRestartAfterUpgrade bool `yaml:"systemd_restart_after_upgrade"`
-		}
+	// Force overwrites existing artifacts of all packages *OPTIONAL*
+	// fpm fails if the artifact it is about to create exists already
+	Force bool `yaml:"force"`
+
+	// LogLevel of fpm for all packages: error|warn|info|debug, defaults to the terse output of fpm *OPTIONAL*
+	// packages may set their own
+	LogLevel string `yaml:"log_level"`
+
+	// ContentRules are named rule sets restricting what packages may contain *OPTIONAL*
+	// packages select them by name in their content_rules
+	ContentRules map[string]ContentRules `yaml:"content_rules"`
+
+	// SystemAccounts are users and groups known to exist on all hosts, e.g. created by configuration management *OPTIONAL*
+	// other accounts owning files or running services have to be created by the maintainer scripts
+	SystemAccounts []string `yaml:"system_accounts"`
+
+	// Policy restricts the names, maintainers and vendors of the packages *OPTIONAL*
+	// a policy file named by the environment variable PACKAGE_POLICY is enforced as well
+	Policy *Policy `yaml:"policy"`
+
+	// report collects the results while building
+	report Report
+
+	// bench records the time of every stage while building
+	bench bool
+
+	// lock records the run for packages.lock, locked is the lockfile the run has to match
+	lock   *Lock
+	locked *Lock
+}
+
+// Package describes a single package entry of the fpm config
+type Package struct {
+
+	// the name of the target package
+	Name string
+
+	// Priority orders the packages of a run *OPTIONAL*
+	// packages with a lower priority are built, reported and published first
+	// packages of equal priority keep the order of packages.yml
+	Priority int `yaml:"priority"`
+
+	// section Source of the fpm config
+	// defines where and how to source the contents of the package
+	Source struct {
+		// source mode specifies how to gather the files contained in the package
+		//
+		// "dir":
+		// use mode dir to source files from a local directory
+		// a valid configuration using "dir" needs at least one argument containing a path
+		//
+		// "pleaserun":
+		// use mode "pleaserun" to install a service for the init system of the host
+		// the first path is the absolute path of the program, the others are its arguments
+		//
+		// "tar":
+		// use mode "tar" to package the contents of a tarball created by an earlier step
+		// a valid configuration using "tar" needs exactly one path to a tarball
+		//
+		// "gem":
+		// use mode "gem" to repackage a ruby gem, the version selects the version of the gem
+		// a valid configuration using "gem" needs exactly one path containing the name of the gem
+		//
+		// "python":
+		// use mode "python" to package a python project or a package of the python package index
+		// a valid configuration using "python" needs exactly one path to a setup.py or the name of a package
+		//
+		// "virtualenv":
+		// use mode "virtualenv" to package a python application with a bundled virtualenv
+		// a valid configuration using "virtualenv" needs either a requirements file or exactly one path containing the name of a package
+		//
+		// "empty":
+		// use mode "empty" to create a metapackage without files that only declares dependencies
+		// a valid configuration using "empty" has no paths
+		//
+		// "deb":
+		// use mode "deb" to repackage an existing debian package e.g. of a vendor
+		// name, scripts, dependencies and conflicts of the target replace those of the original package
+		// a valid configuration using "deb" needs exactly one path to a .deb file
+		//
+		// "rpm":
+		// use mode "rpm" to convert an rpm package e.g. of a vendor only shipping rpms
+		// a valid configuration using "rpm" needs exactly one path to a .rpm file
+		//
+		// "git":
+		// use mode "git" to clone a repository and package its files like mode "dir"
+		// a valid configuration using "git" needs a url, paths are relative to the subdirectory
+		//
+		// "url":
+		// use mode "url" to download a tarball or a binary and package it like mode "dir"
+		// tarballs are extracted, paths are relative to the extracted files or the directory of the binary
+		// a valid configuration using "url" needs an https url and its sha256 checksum
+		//
+		// "docker":
+		// use mode "docker" to package the filesystem of a docker image like mode "dir"
+		// paths are relative to the root of the image or absolute paths inside the image, the whole filesystem by default
+		// a valid configuration using "docker" needs an image
+		//
+		// "go":
+		// use mode "go" to compile go binaries and package them like mode "dir"
+		// the module is read from chdir, additional paths are relative to it
+		// a valid configuration using "go" needs at least one main package
+		//
+		// "artifacts":
+		// use mode "artifacts" to package the output directory of an earlier step or job like mode "dir"
+		// the files are verified against a manifest of sha256 checksums before they are packaged
+		// a valid configuration using "artifacts" needs the directory as chdir and the manifest
+		//
+		// Mode is REQUIRED
+		Mode string `yaml:"mode"`
+
+		// Excludes is used with mode "dir"
+		// paths to files that are explicitly not part of the packages source files
+		Excludes []string `yaml:"excludes"`
+		// ExcludeFile lists further patterns to exclude, one per line *OPTIONAL*
+		// empty lines and lines starting with # are skipped
+		ExcludeFile string `yaml:"exclude_file"`
+
+		Chdir string `yaml:"chdir"`
+
+		// pleaserun specific options *OPTIONAL*
+		// Service is the name of the service, defaults to the package name
+		Service string `yaml:"service"`
+		// User the service runs as, defaults to root
+		User string `yaml:"user"`
+		// WorkingDir of the service
+		WorkingDir string `yaml:"working_dir"`
+
+		// tar specific options *OPTIONAL*
+		// PreserveOwnership keeps the owners, groups and modes stored in the tarball instead of those of the runner
+		// the tarball is extracted as root, only available for target modes rpm, tar and dir
+		PreserveOwnership bool `yaml:"preserve_ownership"`
+
+		// git and url specific options
+		// URL of the repository to clone or the file to download *REQUIRED*
+		URL string `yaml:"url"`
+		// SHA256 is the checksum of the downloaded file *REQUIRED* for mode url
+		SHA256 string `yaml:"sha256"`
+		// Ref is the branch, tag or commit to check out, defaults to the default branch
+		Ref string `yaml:"ref"`
+		// Subdirectory of the repository that is packaged, defaults to the root of the repository
+		Subdirectory string `yaml:"subdirectory"`
+
+		// docker specific options
+		// Image whose filesystem is packaged e.g. "ghcr.io/example/app:1.2.0" *REQUIRED*
+		Image string `yaml:"image"`
+
+		// go specific options
+		// Main are the main packages that are built e.g. "./cmd/example" *REQUIRED*
+		Main []string `yaml:"main"`
+		// GOOS and GOARCH to build for, default to the target mode and architecture of the package
+		GOOS   string `yaml:"goos"`
+		GOARCH string `yaml:"goarch"`
+		// InstallPath is the directory the binaries are installed to, defaults to "/usr/bin"
+		InstallPath string `yaml:"install_path"`
+
+		// artifacts specific options
+		// Manifest lists the checksums of all files in chdir like the output of sha256sum *REQUIRED*
+		Manifest string `yaml:"manifest"`
+
+		// python specific options *OPTIONAL*
+		// Python is the python binary used to build the package e.g. "python3", also used by mode virtualenv
+		Python string `yaml:"python"`
+		// Pip is the pip binary used to download packages, easy_install is used without it
+		Pip string `yaml:"pip"`
+		// InstallLib is the directory python modules are installed to e.g. "/usr/lib/python3/dist-packages"
+		InstallLib string `yaml:"install_lib"`
+		// InstallBin is the directory python scripts are installed to e.g. "/usr/bin"
+		InstallBin string `yaml:"install_bin"`
+
+		// virtualenv specific options *OPTIONAL*
+		// Requirements is a requirements.txt file installed into the virtualenv instead of a single package
+		Requirements string `yaml:"requirements"`
+		// InstallLocation is the directory the virtualenv is installed below, defaults to "/opt"
+		// the virtualenv itself is named like the package
+		InstallLocation string `yaml:"install_location"`
+	} `yaml:"source"`
+
+	// section Target of the fpm config
+	Target struct {
+		// Modes specifies the kinds of package to create *REQUIRED*
+		// a single mode or a list of modes, every mode results in a separate fpm invocation
+		//
+		// "deb":
+		// use mode "deb" to create a debian package
+		// a valid configuration using "deb" needs flags "name"
+		//
+		// "rpm":
+		// use mode "rpm" to create a package for red hat based distributions
+		// a valid configuration using "rpm" needs flags "name" and "version"
+		//
+		// "apk":
+		// use mode "apk" to create a package for alpine linux
+		// a valid configuration using "apk" needs flags "name" and "version"
+		//
+		// "pacman":
+		// use mode "pacman" to create a package for arch linux
+		// a valid configuration using "pacman" needs flags "name" and "version"
+		// install scripts are mapped to the functions of the .INSTALL file
+		//
+		// "tar":
+		// use mode "tar" to create a plain archive of the package files
+		// the archive is compressed according to "compression"
+		//
+		// "freebsd":
+		// use mode "freebsd" to create a .txz package for FreeBSD pkg
+		// a valid configuration using "freebsd" needs flags "name" and "version"
+		//
+		// "osxpkg":
+		// use mode "osxpkg" to create a .pkg installer for macOS, requires pkgbuild of a macOS runner
+		// a valid configuration using "osxpkg" needs flags "name" and "version"
+		//
+		// "sh":
+		// use mode "sh" to create a self-extracting shell script for hosts without a package manager
+		// a valid configuration using "sh" needs flags "name" and "version"
+		//
+		// "zip":
+		// use mode "zip" to create a zip archive of the package files e.g. for windows users
+		//
+		// "dir":
+		// use mode "dir" to materialize the file tree of the package in "output_dir" for inspection
+		Modes Modes `yaml:"mode"`
+
+		// Mode is the kind of package created by a single fpm invocation
+		// it is set when the package entry is expanded into one entry per mode
+		Mode string `yaml:"-"`
+
+		// package Version *REQUIRED*
+		Version string `yaml:"version"`
+
+		// Prefix is prepended to the install path of all files e.g. "/opt/example" *OPTIONAL*
+		Prefix string `yaml:"prefix"`
+
+		// Epoch overrides the version comparison, packages with a higher epoch are always newer *OPTIONAL*
+		// use it to recover from a version that was released by mistake
+		Epoch string `yaml:"epoch"`
+
+		// OutputPath is the file the package is written to e.g. "dist/NAME_FULLVERSION_ARCH.EXTENSION" *OPTIONAL*
+		// fpm replaces NAME, VERSION, FULLVERSION, ITERATION, ARCH, TYPE and EXTENSION, a path ending in / is a directory
+		// the file tree of mode dir is written to output_dir instead
+		OutputPath string `yaml:"output_path"`
+
+		// Iteration distinguishes rebuilds of the same version e.g. 1, 2, 3 *OPTIONAL*
+		// use ${GITHUB_RUN_NUMBER} to count the workflow runs, --append-changelog bumps it
+		Iteration string `yaml:"iteration"`
+
+		// package architecture - defaults to local architecture of whatever machine is building the package
+		// "all" creates an architecture independent package, "native" is the architecture of the build host.
+		// names of other package formats are translated by fpm e.g. x86_64 to amd64 for deb packages
+		Architecture string `yaml:"architecture"`
+
+		// Maintainer of the package *OPTIONAL*
+		// should be an email address
+		Maintainer string `yaml:"maintainer"`
+
+		// Vendor of the package *OPTIONAL*
+		Vendor string `yaml:"vendor"`
+
+		// project URL *OPTIONAL*
+		// will be displayed in the packages metadata alongside the description
+		URL         string `yaml:"url"`
+		License     string `yaml:"license"`
+		Description string `yaml:"description"`
+		// DescriptionFrom reads the description from a markdown file like README.md instead *OPTIONAL*
+		DescriptionFrom *ReadmeDescription `yaml:"description_from"`
+
+		// Section the package belongs to e.g. "utils" or "net" *OPTIONAL*
+		// rpm packages use it as group
+		Section string `yaml:"section"`
+		// Priority of debian packages: required|important|standard|optional|extra *OPTIONAL*
+		Priority string `yaml:"priority"`
+
+		// Changelog is the changelog file of deb and rpm packages, instead of the placeholder of fpm *OPTIONAL*
+		// it has to be formatted like debian/changelog for deb and like the %changelog section for rpm
+		Changelog string `yaml:"changelog"`
+		// UpstreamChangelog is the changelog of the packaged software, only for deb packages *OPTIONAL*
+		UpstreamChangelog string `yaml:"upstream_changelog"`
+
+		// User and Group owning all files of deb and rpm packages, default to root *OPTIONAL*
+		User  string `yaml:"user"`
+		Group string `yaml:"group"`
+
+		// Fields are additional control fields of debian packages e.g. Bugs or XB-Build-Commit *OPTIONAL*
+		Fields map[string]string `yaml:"fields"`
+
+		// CustomControl replaces the control file generated by fpm, only for deb packages *OPTIONAL*
+		// all metadata of the package has to be given in the file, fields and dependencies of packages.yml are ignored
+		CustomControl string `yaml:"custom_control"`
+		// MetaFiles are added to the control archive of deb packages e.g. triggers or templates *OPTIONAL*
+		MetaFiles []string `yaml:"meta_files"`
+		// Triggers are dpkg triggers of deb packages like "activate-noawait ldconfig" or "interest /usr/share/example" *OPTIONAL*
+		Triggers []string `yaml:"triggers"`
+
+		// Provides lists virtual package names the package satisfies e.g. "mta" *OPTIONAL*
+		// a version may only be given exactly like "mta (= 1.0)"
+		Provides []string `yaml:"provides"`
+
+		// special file tags
+		Directories []string `yaml:"directories"`
+		ConfigFiles []string `yaml:"config_files"`
+		Systemd     []string `yaml:"systemd"`
+
+		// NoDefaultConfigFiles stops deb packages from marking all files below /etc as conffiles *OPTIONAL*
+		// only config_files are kept across updates then
+		NoDefaultConfigFiles bool `yaml:"no_default_config_files"`
+
+		// dependency management
+		Depends       []string `yaml:"depends"`
+		Suggests      []string `yaml:"suggests"`
+		NoAutoDepends bool     `yaml:"no_auto_depends"`
+		Conflicts     []string `yaml:"conflicts"`
+
+		// Replaces lists packages whose files this package may overwrite e.g. after a rename *OPTIONAL*
+		// rpm packages obsolete the replaced packages
+		Replaces []string `yaml:"replaces"`
+		// Breaks lists package versions that stop working once this package is installed *OPTIONAL*
+		// combined with replaces files can be taken over from older versions of another package
+		Breaks []string `yaml:"breaks"`
+
+		// Recommends lists packages installed along by default, but not required *OPTIONAL*
+		Recommends []string `yaml:"recommends"`
+		// PreDepends lists packages that have to be configured before the scripts of this package run *OPTIONAL*
+		// use it if e.g. before_install calls a tool of another package
+		PreDepends []string `yaml:"pre_depends"`
+		// BuildDepends records the packages the package was built with in the control file *OPTIONAL*
+		// they are not installed along, only tools inspecting the package read them
+		BuildDepends []string `yaml:"build_depends"`
+
+		// script tags
+		BeforeInstall string `yaml:"before_install"`
+		AfterInstall  string `yaml:"after_install"`
+
+		BeforeRemove string `yaml:"before_remove"`
+		AfterRemove  string `yaml:"after_remove"`
+
+		BeforeUpgrade string `yaml:"before_upgrade"`
+		AfterUpgrade  string `yaml:"after_upgrade"`
+
+		// TemplateScripts renders the scripts as ERB templates, e.g. "<%= name %>" is the package name *OPTIONAL*
+		TemplateScripts bool `yaml:"template_scripts"`
+		// TemplateValues are inserted into the script templates by key e.g. "<%= service %>" *OPTIONAL*
+		// they imply template_scripts, so one script can be shared by several packages
+		TemplateValues map[string]string `yaml:"template_values"`
+
+		// Ldconfig makes deb and rpm packages run ldconfig for their shared libraries *OPTIONAL*
+		// enabled when libraries are installed to e.g. /usr/lib, false leaves it to the scripts
+		Ldconfig *bool `yaml:"ldconfig"`
+
+		SystemdEnable              bool `yaml:"systemd_enable"`
+		SystemdAutoStart           bool `yaml:"systemd_auto_start"`
+		SystemdRestartAfterUpgrade bool `yaml:"systemd_restart_after_upgrade"`
+
+		// rpm specific metadata *OPTIONAL*
+		// Summary is a one line description, defaults to the first line of the description
+		Summary string `yaml:"summary"`
+		// Dist is the distribution tag appended to the release of rpm packages e.g. "el8" *OPTIONAL*
+		// deb packages name it as distribution in the changelog e.g. "jammy", defaults to "unstable"
+		Dist string `yaml:"dist"`
+
+		// Compression of the package *OPTIONAL*
+		// tar archives may be compressed with "gz" (default), "xz", "bz2" or "none"
+		// deb packages with "gz" (default), "xz", "zstd" or "none", dpkg older than 1.21.18 can not install zstd
+		Compression string `yaml:"compression"`
+		// CompressionLevel of xz (0-9) and zstd (1-19), defaults to the level of the compressor *OPTIONAL*
+		CompressionLevel *int `yaml:"compression_level"`
+		// CompressionThreads used by xz and zstd, 0 uses all cores, defaults to a single thread *OPTIONAL*
+		// multithreaded xz splits the data into blocks, so the output differs from single threaded runs
+		CompressionThreads *int `yaml:"compression_threads"`
+
+		// pacman specific metadata *OPTIONAL*
+		// OptDepends lists optional dependencies in the form "package: reason"
+		OptDepends []string `yaml:"optdepends"`
+
+		// apk specific metadata *OPTIONAL*
+		// Origin is the name of the package this package was split from, defaults to the name
+		Origin string `yaml:"origin"`
+		// APKKey is the PEM encoded RSA private key of the maintainer used to sign the package
+		// use an environment variable to keep it out of packages.yml
+		APKKey string `yaml:"apk_key"`
+		// APKKeyName is the file name of the public key installed in /etc/apk/keys on the hosts
+		APKKeyName string `yaml:"apk_key_name"`
+
+		// freebsd specific metadata *OPTIONAL*
+		// FreeBSDOrigin is the port the package is built from in the form "category/name"
+		FreeBSDOrigin string `yaml:"freebsd_origin"`
+		// FreeBSDOSVersion restricts the package to a FreeBSD release e.g. "13", defaults to any release
+		FreeBSDOSVersion string `yaml:"freebsd_osversion"`
+
+		// osxpkg specific metadata *OPTIONAL*
+		// OSXPkgIdentifierPrefix is prepended to the name to form the package identifier e.g. "com.example"
+		OSXPkgIdentifierPrefix string `yaml:"osxpkg_identifier_prefix"`
+		// OSXPkgOwnership sets the ownership of the installed files
+		// "recommended" (default) installs files as root, "preserve" and "preserve-other" keep the owners of the source files
+		OSXPkgOwnership string `yaml:"osxpkg_ownership"`
+
+		// sh specific metadata *OPTIONAL*
+		// PostExtract is a script run by the installer after the files were extracted
+		PostExtract string `yaml:"post_extract"`
+
+		// dir specific options *OPTIONAL*
+		// OutputDir is the directory the file tree is created in, defaults to <name>_<version>
+		// fpm refuses to overwrite an existing directory unless force is set
+		OutputDir string `yaml:"output_dir"`
+	}
+
+	// Force overwrites an existing artifact of the package, e.g. of a previous run in the same workspace *OPTIONAL*
+	Force bool `yaml:"force"`
+
+	// LogLevel of fpm for this package: error|warn|info|debug, info and debug help to find out why fpm failed *OPTIONAL*
+	LogLevel string `yaml:"log_level"`
+
+	Paths []string `yaml:"paths"`
+
+	// PathsFrom is a file listing additional paths, one per line *OPTIONAL*
+	// use "-" to read the list from stdin, e.g. generated by a previous build step
+	// empty lines and lines starting with # are skipped
+	PathsFrom string `yaml:"paths_from"`
+
+	// Sources are merged into a single staging tree that is packaged instead of paths *OPTIONAL*
+	// combines local directories, downloads and generated files, only available for source mode "dir"
+	Sources []SourceSpec `yaml:"sources"`
+
+	// PathPolicy decides how unusual file names, broken symlinks, sockets and named pipes are handled *OPTIONAL*
+	// only available for source mode "dir"
+	PathPolicy *PathPolicy `yaml:"path_policy"`
+
+	// AllowOutsidePaths allows paths containing ".." and symlinks pointing outside of the source root *OPTIONAL*
+	// both fail by default to prevent accidentally packaging or linking files of the build host
+	AllowOutsidePaths bool `yaml:"allow_outside_paths"`
+
+	// Hooks run custom commands after staging and after fpm created the package *OPTIONAL*
+	Hooks Hooks `yaml:"hooks"`
+
+	// LicenseAudit scans the contents for third party licenses and adds a notices file *OPTIONAL*
+	// only available for source mode "dir"
+	LicenseAudit *LicenseAudit `yaml:"license_audit"`
+
+	// Resources limits CPU, IO and memory of the processes building the package *OPTIONAL*
+	Resources *Resources `yaml:"resources"`
+
+	// Shell installs completions and a profile.d snippet of command line tools *OPTIONAL*
+	// only available for source modes dir, git, url, docker, go and artifacts
+	Shell *Shell `yaml:"shell"`
+
+	// Attributes set the mode and owners of installed files without chmod in the maintainer scripts *OPTIONAL*
+	// only available for source modes dir, git, url, docker, go and artifacts
+	Attributes []FileAttribute `yaml:"attributes"`
 
-		Paths []string `yaml:"paths"`
+	// ContentRules are the rule sets the staged files are checked against *OPTIONAL*
+	// entries are names of rule sets of packages.yml or paths of shared yaml files containing a rule set
+	// only available for the source modes of path_policy
+	ContentRules []string `yaml:"content_rules"`
+
+	// Watch lists further files or patterns the package is built from, for selective builds *OPTIONAL*
+	Watch []string `yaml:"watch"`
+
+	// changelog is the changelog file written by --append-changelog
+	changelog string
+
+	// buildFields are the control fields describing the build environment
+	buildFields []string
+
+	// ldconfig activates the ldconfig trigger of deb packages
+	ldconfig bool
+
+	// rpmAttributes are the values of --rpm-attr resolved from the attributes
+	rpmAttributes []string
+
+	// fileExcludes are the patterns read from the exclude file
+	fileExcludes []string
+
+	// remotes are the sources fetched while building and their digests, for the lockfile
+	remotes []remoteSource
+}
+
+// Modes is a list of target modes that may be given as a single string in packages.yml
+type Modes []string
+
+// method UnmarshalYAML accepts a single mode as well as a list of modes
+func (m *Modes) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	single := ""
+	if err := unmarshal(&single); err == nil {
+		*m = Modes{single}
+		return nil
+	}
+	list := []string{}
+	if err := unmarshal(&list); err != nil {
+		return err
+	}
+	*m = list
+	return nil
+}
+
+// method expandModes replaces every package entry with one entry per target mode
+// the expanded entries keep the list of all modes to allow mode specific fields
+func (c *FPMConfig) expandModes() {
+	expanded := []Package{}
+	for _, p := range c.Packages {
+		if len(p.Target.Modes) == 0 {
+			expanded = append(expanded, p)
+			continue
+		}
+		for _, m := range p.Target.Modes {
+			e := p
+			e.Target.Mode = m
+			expanded = append(expanded, e)
+		}
 	}
+	c.Packages = expanded
 }
 
 // function readFile accepts a file path and reads the fpm configuration from that file
@@ -102,8 +618,8 @@ func (c *FPMConfig) ReadFile(path string) error {
 	// read the file from disk
 	fileContents, err := ioutil.ReadFile(path)
 
-	// use ExpandEnv and attempt to insert ${ENVIRONMENT_VARIABLES}
-	fileContents = []byte(os.ExpandEnv(string(fileContents)))
+	// attempt to insert ${ENVIRONMENT_VARIABLES} and ${github.*} template variables
+	fileContents = []byte(os.Expand(string(fileContents), expandVariable))
 
 	if err != nil {
 		return err
@@ -113,9 +629,39 @@ func (c *FPMConfig) ReadFile(path string) error {
 		return err
 	}
 
+	if err := c.readPathLists(); err != nil {
+		return err
+	}
+	if err := c.readExcludeFiles(); err != nil {
+		return err
+	}
+
+	c.expandModes()
+
+	// the global force applies to every package
+	if c.Force {
+		for i := range c.Packages {
+			c.Packages[i].Force = true
+		}
+	}
+	c.applyLogLevel(c.LogLevel, false)
+
 	return nil
 }
 
+// method applyLogLevel sets the log level of fpm for all packages, packages keep their own unless override is set
+func (c *FPMConfig) applyLogLevel(level string, override bool) {
+	if level == "" {
+		return
+	}
+	c.LogLevel = level
+	for i := range c.Packages {
+		if override || c.Packages[i].LogLevel == "" {
+			c.Packages[i].LogLevel = level
+		}
+	}
+}
+
 // function contains decides if a given slice contains a given string
 // arguments are named h (for haystack) and n (for needle)
 // this function is not provided by golang and will be used in the check function below
@@ -140,8 +686,8 @@ type ConfigError struct {
 
 // method Error provides a message for the ConfigError (and implements the Error interface)
 func (c ConfigError) Error() string {
-	return fmt.Sprintf("error in package %s:\n  -> config field %s missing or invalid\n  -> %s\n",
-		c.packageEntry, c.field, c.message)
+	return fmt.Sprintf(tr("error in package %s:\n  -> config field %s missing or invalid\n  -> %s\n"),
+		c.packageEntry, c.field, tr(c.message))
 }
 
 // method check to validate the fpm config
@@ -151,7 +697,7 @@ func (c ConfigError) Error() string {
 func (c *FPMConfig) check() error {
 
 	if len(c.Packages) == 0 {
-		fmt.Print("packages.yml specifies no packages to build\n")
+		fmt.Print(tr("packages.yml specifies no packages to build\n"))
 	}
 
 	// check all packages
@@ -165,209 +711,1913 @@ func (c *FPMConfig) check() error {
 		}
 
 		// check if source mode is set to a valid mode
-		validSourceModes := []string{"dir"}
+		validSourceModes := []string{"dir", "pleaserun", "tar", "gem", "python", "empty", "deb", "rpm", "git", "url", "docker", "go", "virtualenv", "artifacts"}
 		if !contains(validSourceModes, p.Source.Mode) {
 			return ConfigError{
 				packageEntry: p.Name,
 				field:        "source.mode",
 				message: fmt.Sprintf(
-					"source mode is required and may contain %s", strings.Join(validSourceModes, "|")),
+					tr("source mode is required and may contain %s"), strings.Join(validSourceModes, "|")),
 			}
 		}
 
 		// checks for source mode "dir"
 		if p.Source.Mode == "dir" {
 
+			// paths must not leave the source root
+			if err := p.checkTraversal(); err != nil {
+				return err
+			}
+
+			// composite packages are staged from their sources
+			if len(p.Sources) > 0 {
+				if len(p.Paths) > 0 || p.Source.Chdir != "" {
+					return ConfigError{
+						packageEntry: p.Name,
+						field:        "sources",
+						message:      "sources replace paths and chdir, they can not be combined",
+					}
+				}
+				for j := range p.Sources {
+					if err := p.Sources[j].check(p.Name, j); err != nil {
+						return err
+					}
+				}
+			}
+
 			// check whether directories were provided
-			if len(p.Paths) < 1 && p.Source.Chdir == "" {
+			if len(p.Paths) < 1 && p.Source.Chdir == "" && len(p.Sources) == 0 {
 				return ConfigError{
 					packageEntry: p.Name,
 					field:        "paths",
-					message:      "for mode dir it is required to specify a list of file paths (package.paths) or a chdir (package.source.chdir)",
+					message:      "for mode dir it is required to specify a list of file paths (package.paths), a chdir (package.source.chdir) or sources (package.sources)",
 				}
 			}
 		}
 
-		// check if target mode is set to a valid mode
-		validTargetModes := []string{"deb"}
-		if !contains(validTargetModes, p.Target.Mode) {
+		// checks for source mode "pleaserun"
+		if p.Source.Mode == "pleaserun" {
+			if len(p.Paths) < 1 || !filepath.IsAbs(p.Paths[0]) {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "paths",
+					message:      "for mode pleaserun the first path has to be the absolute path of the program to run",
+				}
+			}
+			if len(p.excludes()) > 0 || p.Source.Chdir != "" {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "source.excludes|chdir",
+					message:      "excludes and chdir are not available for source mode pleaserun",
+				}
+			}
+		} else if p.Source.Service != "" || p.Source.User != "" || p.Source.WorkingDir != "" {
 			return ConfigError{
 				packageEntry: p.Name,
-				field:        "target.mode",
-				message: fmt.Sprintf(
-					"target mode is required and may contain %s", strings.Join(validSourceModes, "|")),
+				field:        "source.service|user|working_dir",
+				message:      "service, user and working_dir are only available for source mode pleaserun",
 			}
 		}
 
-		// checks for target mode "deb"
-		if p.Target.Mode == "deb" {
-			if p.Target.Version == "" {
+		// checks for source mode "tar"
+		if p.Source.Mode == "tar" {
+			if len(p.Paths) != 1 || !tarballPattern.MatchString(p.Paths[0]) {
 				return ConfigError{
 					packageEntry: p.Name,
-					field:        "target.version",
-					message:      "debian packages require a version",
+					field:        "paths",
+					message:      "for mode tar exactly one path to a tarball (.tar, .tar.gz, .tgz, .tar.bz2, .tar.xz) is required",
+				}
+			}
+			// the tarball is packaged as is, excludes would silently not apply
+			if len(p.excludes()) > 0 || p.Source.Chdir != "" {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "source.excludes|chdir",
+					message:      "excludes and chdir are not available for source mode tar",
+				}
+			}
+			// fpm resets the ownership of deb, apk and most other packages to root
+			for _, m := range p.Target.Modes {
+				if p.Source.PreserveOwnership && !contains([]string{"rpm", "tar", "dir"}, m) {
+					return ConfigError{
+						packageEntry: p.Name,
+						field:        "source.preserve_ownership",
+						message:      fmt.Sprintf(tr("ownership can not be preserved for target mode %s, only for rpm|tar|dir"), m),
+					}
 				}
 			}
+		} else if p.Source.PreserveOwnership {
+			return ConfigError{
+				packageEntry: p.Name,
+				field:        "source.preserve_ownership",
+				message:      "preserve_ownership is only available for source mode tar",
+			}
 		}
 
-	}
-
-	return nil
-}
-
-// method build will create the packages as specified in packages.yml
-func (c *FPMConfig) build() error {
-	for _, p := range c.Packages {
-		fmt.Printf("building package %s...\n", p.Name)
-
-		// set flags that are always required
-		args := []string{
-			"-s", p.Source.Mode,
-			"-t", p.Target.Mode,
+		// checks for source mode "gem"
+		if p.Source.Mode == "gem" {
+			if len(p.Paths) != 1 {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "paths",
+					message:      "for mode gem exactly one path containing the name of the gem is required",
+				}
+			}
+			if len(p.excludes()) > 0 || p.Source.Chdir != "" {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "source.excludes|chdir",
+					message:      "excludes and chdir are not available for source mode gem",
+				}
+			}
 		}
 
-		// set version from file
-		args = append(args, "-v", p.Target.Version)
+		// checks for source mode "deb"
+		if p.Source.Mode == "deb" {
+			if len(p.Paths) != 1 || !strings.HasSuffix(p.Paths[0], ".deb") {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "paths",
+					message:      "for mode deb exactly one path to a .deb file is required",
+				}
+			}
+			if len(p.excludes()) > 0 || p.Source.Chdir != "" {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "source.excludes|chdir",
+					message:      "excludes and chdir are not available for source mode deb",
+				}
+			}
+		}
 
-		// special flags for the "dir" source mode
-		if p.Source.Mode == "dir" {
-			// append all exclude patterns to the command
-			for _, e := range p.Source.Excludes {
-				args = append(args, fmt.Sprintf("-x %s", e))
+		// checks for source mode "rpm"
+		if p.Source.Mode == "rpm" {
+			if len(p.Paths) != 1 || !strings.HasSuffix(p.Paths[0], ".rpm") {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "paths",
+					message:      "for mode rpm exactly one path to a .rpm file is required",
+				}
+			}
+			if len(p.excludes()) > 0 || p.Source.Chdir != "" {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "source.excludes|chdir",
+					message:      "excludes and chdir are not available for source mode rpm",
+				}
 			}
+		}
 
-                        if p.Source.Chdir != "" {
-                                args = append(args, "-C", p.Source.Chdir)
-                        }
+		// checks for source mode "git"
+		if p.Source.Mode == "git" {
+			if p.Source.URL == "" {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "source.url",
+					message:      "for mode git the url of the repository is required",
+				}
+			}
+			if p.Source.Chdir != "" || traverses(p.Source.Subdirectory) || filepath.IsAbs(p.Source.Subdirectory) {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "source.subdirectory",
+					message:      "use a relative subdirectory of the repository instead of chdir",
+				}
+			}
+		} else if p.Source.Ref != "" || p.Source.Subdirectory != "" {
+			return ConfigError{
+				packageEntry: p.Name,
+				field:        "source.ref|subdirectory",
+				message:      "ref and subdirectory are only available for source mode git",
+			}
 		}
 
-		// special flags for the "deb" target mode
-		if p.Target.Mode == "deb" {
-			// set package name
-			args = append(args, "-n", p.Name)
+		// checks for source mode "docker"
+		if p.Source.Mode == "docker" {
+			if p.Source.Image == "" {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "source.image",
+					message:      "for mode docker the image is required",
+				}
+			}
+			if p.Source.Chdir != "" {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "source.chdir",
+					message:      "chdir is not available for source mode docker, paths are relative to the root of the image",
+				}
+			}
+		} else if p.Source.Image != "" {
+			return ConfigError{
+				packageEntry: p.Name,
+				field:        "source.image",
+				message:      "image is only available for source mode docker",
+			}
+		}
 
-			// metadata flags
-			if p.Target.Maintainer != "" {
-				args = append(args, "-m", p.Target.Maintainer)
+		// checks for source mode "go"
+		if p.Source.Mode == "go" {
+			if len(p.Source.Main) == 0 {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "source.main",
+					message:      "for mode go at least one main package is required",
+				}
 			}
-			if p.Target.URL != "" {
-				args = append(args, "--url", p.Target.URL)
+			if p.Source.InstallPath != "" && !filepath.IsAbs(p.Source.InstallPath) {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "source.install_path",
+					message:      "the install path must be absolute",
+				}
 			}
-			if p.Target.Vendor != "" {
-				args = append(args, "--vendor", p.Target.Vendor)
+			if err := p.checkTraversal(); err != nil {
+				return err
 			}
-			if p.Target.License != "" {
-				args = append(args, "--license", p.Target.License)
+		} else if len(p.Source.Main) > 0 || p.Source.GOOS != "" || p.Source.GOARCH != "" || p.Source.InstallPath != "" {
+			return ConfigError{
+				packageEntry: p.Name,
+				field:        "source.main|goos|goarch|install_path",
+				message:      "main, goos, goarch and install_path are only available for source mode go",
 			}
-                        if p.Target.Description != "" {
-                                args = append(args, "--description", p.Target.Description)
-                        }
-
+		}
 
-			// tag important files
-			for _, d := range p.Target.Directories {
-				args = append(args, "--directories", d)
+		// checks for source mode "url"
+		if p.Source.Mode == "url" {
+			if !strings.HasPrefix(p.Source.URL, "https://") {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "source.url",
+					message:      "for mode url an https url is required",
+				}
 			}
-			for _, c := range p.Target.ConfigFiles {
-				args = append(args, "--config-files", c)
+			if !sha256Pattern.MatchString(p.Source.SHA256) {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "source.sha256",
+					message:      "for mode url the sha256 checksum of the download is required",
+				}
 			}
-			for _, s := range p.Target.Systemd {
-				args = append(args, "--deb-systemd", s)
+			if p.Source.Chdir != "" {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "source.chdir",
+					message:      "chdir is not available for source mode url, paths are relative to the download",
+				}
 			}
-
-			if p.Target.Architecture != "" {
-				args = append(args, "-a", p.Target.Architecture)
+		} else if p.Source.SHA256 != "" || (p.Source.URL != "" && p.Source.Mode != "git") {
+			return ConfigError{
+				packageEntry: p.Name,
+				field:        "source.url|sha256",
+				message:      "url is only available for source modes git and url, sha256 for source mode url",
 			}
+		}
 
-			// append dependencies, suggests and conflicts
-			for _, d := range p.Target.Depends {
-				args = append(args, "-d", d)
+		// checks for source mode "artifacts"
+		if p.Source.Mode == "artifacts" {
+			if p.Source.Chdir == "" || p.Source.Manifest == "" {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "source.chdir|manifest",
+					message:      "for mode artifacts the artifacts directory as chdir and the manifest are required",
+				}
 			}
-			for _, p := range p.Target.Provides {
-				args = append(args, "--provides", p)
+			if err := p.checkTraversal(); err != nil {
+				return err
 			}
-			for _, s := range p.Target.Suggests {
-				args = append(args, "--deb-suggests", s)
+		} else if p.Source.Manifest != "" {
+			return ConfigError{
+				packageEntry: p.Name,
+				field:        "source.manifest",
+				message:      "manifest is only available for source mode artifacts",
 			}
-			for _, c := range p.Target.Conflicts {
-				args = append(args, "--conflicts", c)
+		}
+
+		// checks for source mode "empty"
+		if p.Source.Mode == "empty" && (len(p.Paths) > 0 || len(p.excludes()) > 0 || p.Source.Chdir != "") {
+			return ConfigError{
+				packageEntry: p.Name,
+				field:        "paths|source.excludes|chdir",
+				message:      "metapackages of source mode empty contain no files, remove paths, excludes and chdir",
 			}
+		}
 
-			// add scripts
-			if p.Target.BeforeInstall != "" {
-				args = append(args, "--before-install", p.Target.BeforeInstall)
+		// checks for source mode "python"
+		if p.Source.Mode == "python" {
+			if len(p.Paths) != 1 {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "paths",
+					message:      "for mode python exactly one path to a setup.py or the name of a package is required",
+				}
 			}
-			if p.Target.AfterInstall != "" {
-				args = append(args, "--after-install", p.Target.AfterInstall)
+			if len(p.excludes()) > 0 || p.Source.Chdir != "" {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "source.excludes|chdir",
+					message:      "excludes and chdir are not available for source mode python",
+				}
 			}
-			if p.Target.BeforeRemove != "" {
-				args = append(args, "--before-remove", p.Target.BeforeRemove)
+		} else if p.Source.Pip != "" || p.Source.InstallLib != "" || p.Source.InstallBin != "" {
+			return ConfigError{
+				packageEntry: p.Name,
+				field:        "source.pip|install_lib|install_bin",
+				message:      "pip, install_lib and install_bin are only available for source mode python",
 			}
-			if p.Target.AfterRemove != "" {
-				args = append(args, "--after-remove", p.Target.AfterRemove)
+		}
+
+		// checks for source mode "virtualenv"
+		if p.Source.Mode == "virtualenv" {
+			if (p.Source.Requirements == "") == (len(p.Paths) != 1) {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "source.requirements|paths",
+					message:      "for mode virtualenv either a requirements file or exactly one path containing the name of a package is required",
+				}
 			}
-			if p.Target.BeforeUpgrade != "" {
-				args = append(args, "--before-upgrade", p.Target.BeforeUpgrade)
+			if p.Source.Requirements != "" && !strings.HasSuffix(p.Source.Requirements, "requirements.txt") {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "source.requirements",
+					message:      "fpm only recognizes requirements files named requirements.txt",
+				}
 			}
-			if p.Target.AfterUpgrade != "" {
-				args = append(args, "--after-upgrade", p.Target.AfterUpgrade)
+			if p.Source.InstallLocation != "" && !filepath.IsAbs(p.Source.InstallLocation) {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "source.install_location",
+					message:      "the install location must be absolute",
+				}
 			}
-
-
-
-			// handle systemd units
-			if p.Target.SystemdEnable == true {
-				args = append(args, "--deb-systemd-enable")
+			if len(p.excludes()) > 0 || p.Source.Chdir != "" {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "source.excludes|chdir",
+					message:      "excludes and chdir are not available for source mode virtualenv",
+				}
 			}
-			if p.Target.SystemdAutoStart == true {
-				args = append(args, "--deb-systemd-auto-start")
+		} else if p.Source.Requirements != "" || p.Source.InstallLocation != "" {
+			return ConfigError{
+				packageEntry: p.Name,
+				field:        "source.requirements|install_location",
+				message:      "requirements and install_location are only available for source mode virtualenv",
 			}
-			if p.Target.SystemdRestartAfterUpgrade == true {
-				args = append(args, "--deb-systemd-restart-after-upgrade")
+		} else if p.Source.Python != "" && p.Source.Mode != "python" {
+			return ConfigError{
+				packageEntry: p.Name,
+				field:        "source.python",
+				message:      "python is only available for source modes python and virtualenv",
 			}
+		}
 
+		// composite packages are staged into a directory
+		if len(p.Sources) > 0 && p.Source.Mode != "dir" {
+			return ConfigError{
+				packageEntry: p.Name,
+				field:        "sources",
+				message:      "sources are only available for source mode dir",
+			}
 		}
 
-		// append arguments
-		for _, a := range p.Paths {
-			args = append(args, a)
+		// the path policy inspects the package contents
+		if p.PathPolicy != nil {
+			if !p.inspectable() {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "path_policy",
+					message:      "the path policy is only available for source modes dir, git, url, docker, go and artifacts",
+				}
+			}
+			if err := p.PathPolicy.check(p.Name); err != nil {
+				return err
+			}
 		}
 
-		fmt.Printf("%s %s", "fpm", strings.Join(args, " "))
+		// the license audit inspects the package contents which is only possible for mode "dir"
+		if p.LicenseAudit != nil {
+			if !p.inspectable() {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "license_audit",
+					message:      "the license audit is only available for source modes dir, git, url, docker, go and artifacts",
+				}
+			}
+			if err := p.LicenseAudit.check(p.Name); err != nil {
+				return err
+			}
+		}
 
-		// create the actual command
-		buildCommand := exec.Command("fpm", args...)
+		// content rules inspect the package contents as well
+		if len(p.ContentRules) > 0 {
+			if !p.inspectable() {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "content_rules",
+					message:      "content rules are only available for source modes dir, git, url, docker, go and artifacts",
+				}
+			}
+			if err := c.checkContentRules(&p); err != nil {
+				return err
+			}
+		}
 
-		output, err := buildCommand.CombinedOutput()
-		fmt.Printf(string(output))
+		// limits of the processes building the package
+		if p.Resources != nil {
+			if err := p.Resources.check(&p); err != nil {
+				return err
+			}
+		}
 
-		// exit with non-zero exit code in case the fpm command fails
-		if err != nil {
-			fmt.Printf("FPM command failed\n")
-			os.Exit(2)
+		// verbosity of fpm
+		validLogLevels := []string{"", "error", "warn", "info", "debug"}
+		if !contains(validLogLevels, p.LogLevel) {
+			return ConfigError{
+				packageEntry: p.Name,
+				field:        "log_level",
+				message:      fmt.Sprintf(tr("the log level may contain %s"), strings.Join(validLogLevels[1:], "|")),
+			}
 		}
 
+		// shell integration is added to the paths of the package
+		if p.Shell != nil {
+			if !p.inspectable() {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "shell",
+					message:      "shell integration is only available for source modes dir, git, url, docker, go and artifacts",
+				}
+			}
+			if err := p.Shell.check(&p); err != nil {
+				return err
+			}
+		}
+
+		// attributes of single files are set from the package contents
+		if len(p.Attributes) > 0 && !p.inspectable() {
+			return ConfigError{
+				packageEntry: p.Name,
+				field:        "attributes",
+				message:      "attributes are only available for source modes dir, git, url, docker, go and artifacts",
+			}
+		}
+
+		// check if target mode is set to a valid mode
+		validTargetModes := []string{"deb", "rpm", "apk", "pacman", "tar", "freebsd", "osxpkg", "sh", "zip", "dir"}
+		if !contains(validTargetModes, p.Target.Mode) {
+			return ConfigError{
+				packageEntry: p.Name,
+				field:        "target.mode",
+				message: fmt.Sprintf(
+					tr("target mode is required and may contain %s"), strings.Join(validTargetModes, "|")),
+			}
+		}
+
+		// every mode may only be given once
+		for j, m := range p.Target.Modes {
+			if contains(p.Target.Modes[:j], m) {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "target.mode",
+					message:      fmt.Sprintf(tr("target mode %s is listed more than once"), m),
+				}
+			}
+		}
+
+		if p.Target.Prefix != "" && !filepath.IsAbs(p.Target.Prefix) {
+			return ConfigError{
+				packageEntry: p.Name,
+				field:        "target.prefix",
+				message:      "the prefix must be an absolute path",
+			}
+		}
+
+		if _, err := strconv.ParseUint(p.Target.Epoch, 10, 32); p.Target.Epoch != "" && err != nil {
+			return ConfigError{
+				packageEntry: p.Name,
+				field:        "target.epoch",
+				message:      "the epoch must be a non-negative number",
+			}
+		}
+		if p.Target.Epoch != "" && !contains(p.Target.Modes, "deb") && !contains(p.Target.Modes, "rpm") &&
+			!contains(p.Target.Modes, "pacman") {
+			return ConfigError{
+				packageEntry: p.Name,
+				field:        "target.epoch",
+				message:      "epoch is only available for target modes deb, rpm and pacman",
+			}
+		}
+
+		if p.Target.Iteration != "" && !iterationPattern.MatchString(p.Target.Iteration) {
+			return ConfigError{
+				packageEntry: p.Name,
+				field:        "target.iteration",
+				message:      "the iteration may only contain letters, digits and the characters . + ~",
+			}
+		}
+
+		if p.Target.Architecture != "" && !architecturePattern.MatchString(p.Target.Architecture) {
+			return ConfigError{
+				packageEntry: p.Name,
+				field:        "target.architecture",
+				message:      "the architecture must be a single lowercase word like amd64, arm64 or all",
+			}
+		}
+
+		for _, provided := range p.Target.Provides {
+			if !providesPattern.MatchString(provided) {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "target.provides",
+					message:      fmt.Sprintf(tr("%q is not a package name, versions of provides must be exact like \"mta (= 1.0)\""), provided),
+				}
+			}
+		}
+
+		// checks for target mode "deb"
+		if p.Target.Mode == "deb" {
+			if p.Target.Version == "" {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "target.version",
+					message:      "debian packages require a version",
+				}
+			}
+			// files of the control archive created by fpm itself
+			for _, m := range p.Target.MetaFiles {
+				reserved := []string{"control", "md5sums", "conffiles", "preinst", "postinst", "prerm", "postrm"}
+				if contains(reserved, filepath.Base(m)) {
+					return ConfigError{
+						packageEntry: p.Name,
+						field:        "target.meta_files",
+						message: fmt.Sprintf(tr("%s is created by fpm, use custom_control, config_files or the scripts instead"),
+							filepath.Base(m)),
+					}
+				}
+			}
+			if err := p.checkTriggers(); err != nil {
+				return err
+			}
+			if p.Target.Dist != "" && !distributionPattern.MatchString(p.Target.Dist) {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "target.dist",
+					message:      "the distribution must be a single suite name like jammy or bookworm",
+				}
+			}
+			for name, value := range p.Target.Fields {
+				if !fieldNamePattern.MatchString(name) || strings.Contains(value, "\n") {
+					return ConfigError{
+						packageEntry: p.Name,
+						field:        "target.fields." + name,
+						message:      "control fields need a name of letters, digits and dashes and a single line value",
+					}
+				}
+			}
+			validPriorities := []string{"", "required", "important", "standard", "optional", "extra"}
+			if !contains(validPriorities, p.Target.Priority) {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "target.priority",
+					message:      fmt.Sprintf(tr("the priority may contain %s"), strings.Join(validPriorities[1:], "|")),
+				}
+			}
+		} else if !contains(p.Target.Modes, "deb") {
+			for _, f := range []struct {
+				name string
+				set  bool
+			}{
+				{"breaks", len(p.Target.Breaks) > 0},
+				{"recommends", len(p.Target.Recommends) > 0},
+				{"pre_depends", len(p.Target.PreDepends) > 0},
+				{"build_depends", len(p.Target.BuildDepends) > 0},
+				{"priority", p.Target.Priority != ""},
+				{"fields", len(p.Target.Fields) > 0},
+				{"upstream_changelog", p.Target.UpstreamChangelog != ""},
+				{"custom_control", p.Target.CustomControl != ""},
+				{"meta_files", len(p.Target.MetaFiles) > 0},
+				{"no_default_config_files", p.Target.NoDefaultConfigFiles},
+				{"triggers", len(p.Target.Triggers) > 0},
+				{"systemd", len(p.Target.Systemd) > 0},
+				{"suggests", len(p.Target.Suggests) > 0},
+				{"systemd_enable", p.Target.SystemdEnable},
+				{"systemd_auto_start", p.Target.SystemdAutoStart},
+				{"systemd_restart_after_upgrade", p.Target.SystemdRestartAfterUpgrade},
+			} {
+				if f.set {
+					return ConfigError{
+						packageEntry: p.Name,
+						field:        "target." + f.name,
+						message:      f.name + " is only available for target mode deb",
+					}
+				}
+			}
+		}
+
+		// checks for target mode "rpm"
+		if p.Target.Mode == "rpm" {
+			if p.Target.Version == "" {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "target.version",
+					message:      "rpm packages require a version",
+				}
+			}
+			// rpm does not allow dashes in versions, they separate version and release
+			if strings.Contains(p.Target.Version, "-") {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "target.version",
+					message:      "rpm versions must not contain dashes",
+				}
+			}
+		} else if !contains(p.Target.Modes, "rpm") && p.Target.Summary != "" {
+			return ConfigError{
+				packageEntry: p.Name,
+				field:        "target.summary",
+				message:      "summary is only available for target mode rpm",
+			}
+		}
+
+		// rpm packages append the dist to the release, deb packages name it in the changelog
+		if p.Target.Dist != "" && !contains(p.Target.Modes, "rpm") && !contains(p.Target.Modes, "deb") {
+			return ConfigError{
+				packageEntry: p.Name,
+				field:        "target.dist",
+				message:      "dist is only available for target modes deb and rpm",
+			}
+		}
+
+		// checks for target mode "pacman"
+		if p.Target.Mode == "pacman" {
+			if p.Target.Version == "" || strings.ContainsAny(p.Target.Version, "-:/ ") {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "target.version",
+					message:      "pacman packages require a version without dashes, colons, slashes or spaces",
+				}
+			}
+		} else if !contains(p.Target.Modes, "pacman") && len(p.Target.OptDepends) > 0 {
+			return ConfigError{
+				packageEntry: p.Name,
+				field:        "target.optdepends",
+				message:      "optdepends are only available for target mode pacman",
+			}
+		}
+
+		// checks for the compression of target modes "tar" and "deb"
+		validCompressions := map[string][]string{
+			"tar": {"", "gz", "xz", "bz2", "none"},
+			"deb": {"", "gz", "xz", "zstd", "none"},
+		}
+		if valid, ok := validCompressions[p.Target.Mode]; ok {
+			if !contains(valid, p.Target.Compression) {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "target.compression",
+					message: fmt.Sprintf(tr("%s packages may be compressed with %s"),
+						p.Target.Mode, strings.Join(valid[1:], "|")),
+				}
+			}
+		} else if !contains(p.Target.Modes, "tar") && !contains(p.Target.Modes, "deb") && p.Target.Compression != "" {
+			return ConfigError{
+				packageEntry: p.Name,
+				field:        "target.compression",
+				message:      "compression is only available for target modes tar and deb",
+			}
+		}
+		if err := p.checkCompression(); err != nil {
+			return err
+		}
+
+		// only deb and rpm packages carry a changelog
+		if p.Target.Changelog != "" && !contains(p.Target.Modes, "deb") && !contains(p.Target.Modes, "rpm") {
+			return ConfigError{
+				packageEntry: p.Name,
+				field:        "target.changelog",
+				message:      "changelog is only available for target modes deb and rpm",
+			}
+		}
+
+		// owners of the packaged files
+		if p.Target.User != "" || p.Target.Group != "" {
+			if !contains(p.Target.Modes, "deb") && !contains(p.Target.Modes, "rpm") {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "target.user|group",
+					message:      "user and group are only available for target modes deb and rpm",
+				}
+			}
+			if p.Source.PreserveOwnership {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "target.user|group",
+					message:      "user and group would replace the ownership preserved from the tarball",
+				}
+			}
+		}
+
+		// ldconfig is triggered by deb packages and run by the scripts of rpm packages
+		if p.Target.Ldconfig != nil && *p.Target.Ldconfig && !contains(p.Target.Modes, "deb") && !contains(p.Target.Modes, "rpm") {
+			return ConfigError{
+				packageEntry: p.Name,
+				field:        "target.ldconfig",
+				message:      "ldconfig is only available for target modes deb and rpm",
+			}
+		}
+
+		// owners of single files are declared in the spec of rpm packages
+		for j, a := range p.Attributes {
+			if err := a.check(&p, j); err != nil {
+				return err
+			}
+		}
+
+		// checks for target mode "freebsd"
+		if p.Target.Mode == "freebsd" {
+			// pkg separates name and version by the last dash, so names must not end in a version
+			if strings.ContainsAny(p.Name, " /") || freebsdVersionSuffix.MatchString(p.Name) {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "name",
+					message:      "freebsd package names must not contain spaces or slashes or end in -<number>",
+				}
+			}
+			// underscores and commas separate the port revision and epoch from the version
+			if p.Target.Version == "" || strings.ContainsAny(p.Target.Version, "-_, ") {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "target.version",
+					message:      "freebsd packages require a version without dashes, underscores, commas or spaces",
+				}
+			}
+			if p.Target.FreeBSDOrigin != "" && strings.Count(p.Target.FreeBSDOrigin, "/") != 1 {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "target.freebsd_origin",
+					message:      "the origin must be given as category/name",
+				}
+			}
+		} else if !contains(p.Target.Modes, "freebsd") && (p.Target.FreeBSDOrigin != "" || p.Target.FreeBSDOSVersion != "") {
+			return ConfigError{
+				packageEntry: p.Name,
+				field:        "target.freebsd_origin|freebsd_osversion",
+				message:      "freebsd_origin and freebsd_osversion are only available for target mode freebsd",
+			}
+		}
+
+		// checks for target mode "osxpkg"
+		if p.Target.Mode == "osxpkg" {
+			if p.Target.Version == "" {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "target.version",
+					message:      "osxpkg packages require a version",
+				}
+			}
+			if p.Target.OSXPkgIdentifierPrefix != "" && !osxIdentifierPattern.MatchString(p.Target.OSXPkgIdentifierPrefix) {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "target.osxpkg_identifier_prefix",
+					message:      "the identifier prefix must be in reverse domain notation e.g. com.example",
+				}
+			}
+			validOwnerships := []string{"", "recommended", "preserve", "preserve-other"}
+			if !contains(validOwnerships, p.Target.OSXPkgOwnership) {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "target.osxpkg_ownership",
+					message:      fmt.Sprintf(tr("ownership may contain %s"), strings.Join(validOwnerships[1:], "|")),
+				}
+			}
+		} else if !contains(p.Target.Modes, "osxpkg") && (p.Target.OSXPkgIdentifierPrefix != "" || p.Target.OSXPkgOwnership != "") {
+			return ConfigError{
+				packageEntry: p.Name,
+				field:        "target.osxpkg_identifier_prefix|osxpkg_ownership",
+				message:      "osxpkg_identifier_prefix and osxpkg_ownership are only available for target mode osxpkg",
+			}
+		}
+
+		// checks for target mode "sh"
+		if p.Target.Mode == "sh" {
+			if p.Target.Version == "" {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "target.version",
+					message:      "sh installers require a version",
+				}
+			}
+			// the installer runs the after install script after extracting
+			if p.Target.PostExtract != "" && p.Target.AfterInstall != "" {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "target.post_extract",
+					message:      "sh installers may either have a post_extract or an after_install script",
+				}
+			}
+		} else if !contains(p.Target.Modes, "sh") && p.Target.PostExtract != "" {
+			return ConfigError{
+				packageEntry: p.Name,
+				field:        "target.post_extract",
+				message:      "post_extract is only available for target mode sh",
+			}
+		}
+
+		// output_dir only applies to target mode "dir"
+		if !contains(p.Target.Modes, "dir") && p.Target.OutputDir != "" {
+			return ConfigError{
+				packageEntry: p.Name,
+				field:        "target.output_dir",
+				message:      "output_dir is only available for target mode dir",
+			}
+		}
+
+		// output_path replaces the file name fpm chooses, file trees are written to output_dir instead
+		if p.Target.OutputPath != "" {
+			output := p.Target.OutputPath
+			switch {
+			case p.Target.Mode == "dir":
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "target.output_path",
+					message:      "output_path is not available for target mode dir, use output_dir",
+				}
+			case p.Target.Mode == "tar" && p.Target.Compression != "":
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "target.output_path",
+					message:      "the compression of tar archives follows from the extension of output_path, remove compression",
+				}
+			case len(p.Target.Modes) > 1 && !strings.HasSuffix(output, "/") &&
+				!strings.Contains(output, "EXTENSION") && !strings.Contains(output, "TYPE"):
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "target.output_path",
+					message:      "packages of several target modes would be written to the same file, add EXTENSION or TYPE to output_path",
+				}
+			}
+		}
+
+		// maintainer scripts must at least be valid shell scripts
+		if err := p.checkTemplateValues(); err != nil {
+			return err
+		}
+		if err := p.checkScripts(); err != nil {
+			return err
+		}
+
+		// files and services must not end up owned by accounts missing on the hosts
+		if err := p.checkAccounts(c.SystemAccounts); err != nil {
+			return err
+		}
+
+		// checks for target mode "apk"
+		if p.Target.Mode == "apk" {
+			if !apkVersionPattern.MatchString(p.Target.Version) {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "target.version",
+					message:      "apk packages require a version like 1.2.3, 1.2.3_rc1 or 1.2.3-r1",
+				}
+			}
+			if p.Target.APKKeyName == "" && p.Target.APKKey != "" {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "target.apk_key_name",
+					message:      "signing apk packages requires apk_key_name",
+				}
+			}
+			if p.Target.APKKeyName != "" && p.Target.APKKey == "" && c.Signing == nil {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "target.apk_key",
+					message:      "signing apk packages requires apk_key or a signer configured in signing",
+				}
+			}
+			if p.Target.APKKeyName != "" && p.Target.APKKey == "" && !c.Signing.signsAPK() {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        "target.apk_key",
+					message:      "apk packages need RSA signatures over SHA-1, use apk_key, signer command or signer vault with algorithm sha1",
+				}
+			}
+		} else if !contains(p.Target.Modes, "apk") && (p.Target.Origin != "" || p.Target.APKKey != "" || p.Target.APKKeyName != "") {
+			return ConfigError{
+				packageEntry: p.Name,
+				field:        "target.origin|apk_key|apk_key_name",
+				message:      "origin, apk_key and apk_key_name are only available for target mode apk",
+			}
+		}
+
+	}
+
+	// check vulnerability scan configuration
+	if c.Scan != nil {
+		validScanners := []string{"grype", "trivy"}
+		if !contains(validScanners, c.Scan.Scanner) {
+			return ConfigError{
+				field: "scan.scanner",
+				message: fmt.Sprintf(
+					tr("scanner is required and may contain %s"), strings.Join(validScanners, "|")),
+			}
+		}
+		for _, t := range []struct{ field, severity string }{{"scan.fail_on", c.Scan.FailOn}, {"scan.warn_on", c.Scan.WarnOn}} {
+			if t.severity != "" && severityRank(t.severity) < 0 {
+				return ConfigError{
+					field: t.field,
+					message: fmt.Sprintf(
+						tr("severity may contain %s"), strings.Join(severities, "|")),
+				}
+			}
+		}
+	}
+
+	// check drift configuration
+	if c.Drift != nil {
+		if err := c.Drift.check(); err != nil {
+			return err
+		}
+	}
+
+	// check expiry configuration
+	if c.Expiry != nil {
+		if err := c.Expiry.check(); err != nil {
+			return err
+		}
+	}
+
+	// check signing configuration
+	if c.Signing != nil {
+		if err := c.Signing.check(); err != nil {
+			return err
+		}
+	}
+
+	// check the package policies
+	if err := c.checkPolicies(); err != nil {
+		return err
+	}
+
+	// check publish configuration
+	for i := range c.Publish {
+		if err := c.Publish[i].check(i); err != nil {
+			return err
+		}
+	}
+
+	// check release notes configuration
+	if c.ReleaseNotes != nil {
+		validAttachTargets := []string{"summary", "release"}
+		for _, a := range c.ReleaseNotes.Attach {
+			if !contains(validAttachTargets, a) {
+				return ConfigError{
+					field: "release_notes.attach",
+					message: fmt.Sprintf(
+						tr("release notes may be attached to %s"), strings.Join(validAttachTargets, "|")),
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// method inspectable decides whether the files of the package are known before fpm runs
+// git, url, docker, go and artifacts sources are turned into dir sources when they are built
+func (p *Package) inspectable() bool {
+	return contains([]string{"dir", "git", "url", "docker", "go", "artifacts"}, p.Source.Mode)
+}
+
+// method order sorts the packages by priority
+// the sort is stable so packages of equal priority keep the order of packages.yml,
+// which keeps builds, reports and release notes in the same order between runs
+func (c *FPMConfig) order() {
+	sort.SliceStable(c.Packages, func(i, j int) bool {
+		return c.Packages[i].Priority < c.Packages[j].Priority
+	})
+}
+
+// method build will create the packages as specified in packages.yml
+func (c *FPMConfig) build() error {
+	for i := range c.Packages {
+		// changes to the package like the staging directory are kept for the release notes
+		p := &c.Packages[i]
+		fmt.Printf(tr("building %s package %s...\n"), p.Target.Mode, p.Name)
+		ui.building(i)
+
+		r := PackageReport{
+			Name:    p.Name,
+			Mode:    p.Target.Mode,
+			Version: p.Target.Version,
+			Status:  "success",
+		}
+		if c.Expiry != nil {
+			r.Expires = c.Expiry.expires()
+		}
+		lap := c.stopwatch(&r)
+
+		// clone the repository of source mode git
+		if p.Source.Mode == "git" {
+			if err := p.checkout(); err != nil {
+				fmt.Printf(tr("could not clone %s: %s\n"), p.Source.URL, err)
+				c.fail(r)
+			}
+		}
+
+		// export the filesystem of source mode docker
+		if p.Source.Mode == "docker" {
+			if err := p.exportImage(); err != nil {
+				fmt.Printf(tr("could not export %s: %s\n"), p.Source.Image, err)
+				c.fail(r)
+			}
+		}
+
+		// compile the binaries of source mode go
+		if p.Source.Mode == "go" {
+			if err := p.goBuild(); err != nil {
+				fmt.Printf(tr("could not build %s: %s\n"), p.Name, err)
+				c.fail(r)
+			}
+		}
+
+		// download the file of source mode url
+		if p.Source.Mode == "url" {
+			if err := p.fetch(); err != nil {
+				fmt.Printf(tr("could not download %s: %s\n"), p.Source.URL, err)
+				c.fail(r)
+			}
+		}
+
+		// verify the files of source mode artifacts against the manifest
+		if p.Source.Mode == "artifacts" {
+			if err := p.verifyArtifacts(); err != nil {
+				fmt.Printf(tr("artifacts of %s do not match %s: %s\n"), p.Name, p.Source.Manifest, err)
+				c.fail(r)
+			}
+		}
+
+		// extract tarballs whose ownership is preserved
+		if p.Source.Mode == "tar" && p.Source.PreserveOwnership {
+			if err := p.importTarball(); err != nil {
+				fmt.Printf(tr("could not extract %s: %s\n"), p.Paths[0], err)
+				c.fail(r)
+			}
+		}
+
+		// merge the sources of composite packages into a staging tree
+		if len(p.Sources) > 0 {
+			staging, err := p.stage()
+			if err != nil {
+				fmt.Printf(tr("could not stage sources of %s: %s\n"), p.Name, err)
+				c.fail(r)
+			}
+			p.Source.Chdir = staging
+			p.Paths = []string{"."}
+		}
+
+		// remote sources have to match the lockfile
+		for _, s := range p.remotes {
+			if err := c.lockSource(s); err != nil {
+				fmt.Printf("%s\n", err)
+				c.fail(r)
+			}
+		}
+
+		if err := runHooks("post_stage", p.Hooks.PostStage, p.hookEnv()); err != nil {
+			fmt.Printf("%s\n", err)
+			c.fail(r)
+		}
+
+		// symlinks must not point outside of the source root
+		if p.Source.Mode == "dir" && !p.AllowOutsidePaths {
+			if err := p.checkEscapes(); err != nil {
+				fmt.Printf(tr("invalid symlink in package %s: %s\n"), p.Name, err)
+				c.fail(r)
+			}
+		}
+
+		// check the file names, skipped files are excluded
+		if p.PathPolicy != nil {
+			excludes, warnings, err := p.applyPathPolicy()
+			if err != nil {
+				fmt.Printf(tr("invalid file name in package %s: %s\n"), p.Name, err)
+				c.fail(r)
+			}
+			p.Source.Excludes = append(append([]string{}, p.Source.Excludes...), excludes...)
+			r.Warnings = append(r.Warnings, warnings...)
+		}
+
+		// check what is packaged against the content rules
+		if len(p.ContentRules) > 0 {
+			if err := c.applyContentRules(p); err != nil {
+				fmt.Printf("%s", err)
+				c.fail(r)
+			}
+		}
+
+		// shared libraries have to be known to the dynamic linker
+		libraries, err := p.addLdconfig()
+		if err != nil {
+			fmt.Printf(tr("could not add ldconfig to package %s: %s\n"), p.Name, err)
+			c.fail(r)
+		}
+		if len(libraries) > 0 {
+			fmt.Printf(tr("package %s installs %d shared libraries, ldconfig runs after installing it\n"), p.Name, len(libraries))
+		}
+
+		// systemd units have to run programs that exist on the hosts
+		warnings, err := p.checkUnits()
+		if err != nil {
+			fmt.Printf(tr("could not check the systemd units of package %s: %s\n"), p.Name, err)
+			c.fail(r)
+		}
+		r.Warnings = append(r.Warnings, warnings...)
+
+		paths := p.Paths
+
+		// add the third party notices found by the license audit
+		if p.LicenseAudit != nil {
+			notices, err := p.licenseNotices(&r)
+			if obligation, ok := err.(licenseObligationError); ok {
+				fmt.Printf(tr("package %s: %s\n"), p.Name, obligation)
+				c.fail(r)
+			}
+			if err != nil {
+				fmt.Printf(tr("could not run the license audit of package %s: %s\n"), p.Name, err)
+				c.fail(r)
+			}
+			if len(paths) == 0 {
+				paths = append(paths, ".")
+			}
+			paths = append(paths, notices...)
+		}
+
+		// install the shell completions and the profile.d snippet
+		if p.Shell != nil {
+			shellPaths, err := p.Shell.paths(p)
+			if err != nil {
+				fmt.Printf(tr("could not add the shell integration of package %s: %s\n"), p.Name, err)
+				c.fail(r)
+			}
+			if len(paths) == 0 {
+				paths = append(paths, ".")
+			}
+			paths = append(paths, shellPaths...)
+		}
+
+		// the requirements file replaces the package name of source mode virtualenv
+		if p.Source.Requirements != "" {
+			paths = []string{p.Source.Requirements}
+		}
+
+		// modes and owners of single files
+		restoreModes, err := p.applyAttributes()
+		if err != nil {
+			fmt.Printf(tr("could not apply the attributes of package %s: %s\n"), p.Name, err)
+			c.fail(r)
+		}
+
+		args := p.args(paths)
+		lap("staging")
+
+		fmt.Printf("%s %s", "fpm", strings.Join(args, " "))
+
+		// fpm does not create the directory of the output path
+		if p.Target.OutputPath != "" && p.Target.Mode != "dir" {
+			dir := p.Target.OutputPath
+			if !strings.HasSuffix(dir, "/") {
+				dir = filepath.Dir(dir)
+			}
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				fmt.Printf(tr("could not create the directory of %s: %s\n"), p.Target.OutputPath, err)
+				restoreModes()
+				c.fail(r)
+			}
+		}
+
+		// create the actual command
+		name, wrapped := p.Resources.command("fpm", args...)
+		buildCommand := exec.Command(name, wrapped...)
+
+		// the compressors and virtualenv read their options from the environment, fpm has no flags for them
+		env := p.compressionEnv()
+		if p.Source.Mode == "virtualenv" && p.Source.Python != "" {
+			env = append(env, "VIRTUALENV_PYTHON="+p.Source.Python)
+		}
+		if len(env) > 0 {
+			buildCommand.Env = append(os.Environ(), env...)
+		}
+
+		output, err := buildCommand.CombinedOutput()
+		restoreModes()
+		fmt.Printf(string(output))
+		lap("fpm")
+
+		// exit with non-zero exit code in case the fpm command fails
+		if err != nil {
+			fmt.Printf(tr("FPM command failed\n"))
+			c.fail(r)
+		}
+
+		r.Artifact = artifactPath(output)
+
+		// fpm does not write all apk metadata and can not sign apk packages
+		if p.Target.Mode == "apk" {
+			var signer Signer
+			if p.Target.APKKey != "" {
+				if signer, err = newRSASigner([]byte(p.Target.APKKey)); err != nil {
+					fmt.Printf(tr("invalid apk_key of %s: %s\n"), p.Name, err)
+					c.fail(r)
+				}
+			} else if p.Target.APKKeyName != "" {
+				signer = c.Signing.signer()
+			}
+			if err := p.finishApk(r.Artifact, signer); err != nil {
+				fmt.Printf(tr("could not finish apk package %s: %s\n"), p.Name, err)
+				c.fail(r)
+			}
+		}
+
+		if err := runHooks("post_build", p.Hooks.PostBuild, append(p.hookEnv(), "ARTIFACT="+r.Artifact)); err != nil {
+			fmt.Printf("%s\n", err)
+			c.fail(r)
+		}
+
+		// create a detached signature of the package, file trees of mode "dir" are not signed
+		if c.Signing != nil && p.Target.Mode != "dir" {
+			if r.Signatures, err = signArtifact(c.Signing.signers(), r.Artifact); err != nil {
+				fmt.Printf(tr("could not sign %s: %s\n"), r.Artifact, err)
+				c.fail(r)
+			}
+		}
+
+		// scan the package for known vulnerabilities
+		if c.Scan != nil {
+			if err := c.Scan.run(r.Artifact, &r); err != nil {
+				fmt.Printf(tr("vulnerability scan of %s failed: %s\n"), p.Name, err)
+				c.fail(r)
+			}
+		}
+
+		// compare size and number of files with the previous release
+		if c.Drift != nil {
+			if err := c.Drift.run(p, &r); err != nil {
+				fmt.Printf(tr("drift check of %s failed: %s\n"), p.Name, err)
+				c.fail(r)
+			}
+		}
+
+		lap("verification")
+
+		for _, w := range r.Warnings {
+			fmt.Printf(tr("warning: %s\n"), w)
+		}
+		c.report.Packages = append(c.report.Packages, r)
+		ui.built(r)
+
 		// print newlines to separate next package
 		fmt.Printf("\n\n")
 	}
 	return nil
 }
 
+// method args creates the fpm arguments for the package
+// paths are appended as arguments after all flags
+func (p *Package) args(paths []string) []string {
+	// set flags that are always required
+	args := []string{
+		"-s", p.Source.Mode,
+		"-t", p.Target.Mode,
+	}
+
+	// set version from file
+	args = append(args, "-v", p.Target.Version)
+	if p.Target.Epoch != "" && contains([]string{"deb", "rpm", "pacman"}, p.Target.Mode) {
+		args = append(args, "--epoch", p.Target.Epoch)
+	}
+	if p.Target.Prefix != "" {
+		args = append(args, "--prefix", p.Target.Prefix)
+	}
+	if p.Target.Iteration != "" {
+		args = append(args, "--iteration", p.Target.Iteration)
+	}
+	if p.Force {
+		args = append(args, "-f")
+	}
+	switch p.LogLevel {
+	case "":
+	case "info":
+		args = append(args, "--verbose")
+	case "debug":
+		args = append(args, "--debug")
+	default:
+		args = append(args, "--log", p.LogLevel)
+	}
+	if p.Target.OutputPath != "" && p.Target.Mode != "dir" {
+		args = append(args, "-p", p.Target.OutputPath)
+	}
+
+	// special flags for the "dir" source mode
+	if p.Source.Mode == "dir" {
+		// append all exclude patterns to the command
+		for _, e := range p.Source.Excludes {
+			args = append(args, "-x", e)
+		}
+		if p.Source.ExcludeFile != "" {
+			args = append(args, "--exclude-file", p.Source.ExcludeFile)
+		}
+
+		if p.Source.Chdir != "" {
+			args = append(args, "-C", p.Source.Chdir)
+		}
+	}
+
+	// special flags for the "pleaserun" source mode
+	if p.Source.Mode == "pleaserun" {
+		service := p.Source.Service
+		if service == "" {
+			service = p.Name
+		}
+		args = append(args, "--pleaserun-name", service)
+		if p.Source.User != "" {
+			args = append(args, "--pleaserun-user", p.Source.User)
+		}
+		if p.Source.WorkingDir != "" {
+			args = append(args, "--pleaserun-chdir", p.Source.WorkingDir)
+		}
+	}
+
+	// special flags for the "python" source mode
+	if p.Source.Mode == "python" {
+		if p.Source.Python != "" {
+			args = append(args, "--python-bin", p.Source.Python)
+		}
+		if p.Source.Pip != "" {
+			args = append(args, "--python-pip", p.Source.Pip)
+		}
+		if p.Source.InstallLib != "" {
+			args = append(args, "--python-install-lib", p.Source.InstallLib)
+		}
+		if p.Source.InstallBin != "" {
+			args = append(args, "--python-install-bin", p.Source.InstallBin)
+		}
+	}
+
+	// special flags for the "virtualenv" source mode
+	if p.Source.Mode == "virtualenv" {
+		location := p.Source.InstallLocation
+		if location == "" {
+			location = "/opt"
+		}
+		args = append(args, "--virtualenv-install-location", location)
+	}
+
+	// set package name
+	args = append(args, "-n", p.Name)
+
+	// metadata flags
+	if p.Target.Maintainer != "" {
+		args = append(args, "-m", p.Target.Maintainer)
+	}
+	if p.Target.URL != "" {
+		args = append(args, "--url", p.Target.URL)
+	}
+	if p.Target.Vendor != "" {
+		args = append(args, "--vendor", p.Target.Vendor)
+	}
+	if p.Target.License != "" {
+		args = append(args, "--license", p.Target.License)
+	}
+	if p.Target.Description != "" {
+		args = append(args, "--description", p.Target.Description)
+	}
+
+	// tag important files
+	for _, d := range p.Target.Directories {
+		args = append(args, "--directories", d)
+	}
+	for _, c := range p.Target.ConfigFiles {
+		args = append(args, "--config-files", c)
+	}
+
+	if p.Target.Architecture != "" {
+		args = append(args, "-a", p.Target.Architecture)
+	}
+	if p.Target.Section != "" {
+		args = append(args, "--category", p.Target.Section)
+	}
+
+	// append dependencies, provides, conflicts and replaced packages
+	for _, d := range p.Target.Depends {
+		args = append(args, "-d", d)
+	}
+	for _, p := range p.Target.Provides {
+		args = append(args, "--provides", p)
+	}
+	for _, c := range p.Target.Conflicts {
+		args = append(args, "--conflicts", c)
+	}
+	for _, r := range p.Target.Replaces {
+		args = append(args, "--replaces", r)
+	}
+
+	// add scripts
+	if p.Target.BeforeInstall != "" {
+		args = append(args, "--before-install", p.Target.BeforeInstall)
+	}
+	if p.Target.AfterInstall != "" {
+		args = append(args, "--after-install", p.Target.AfterInstall)
+	}
+	if p.Target.BeforeRemove != "" {
+		args = append(args, "--before-remove", p.Target.BeforeRemove)
+	}
+	if p.Target.AfterRemove != "" {
+		args = append(args, "--after-remove", p.Target.AfterRemove)
+	}
+	if p.Target.BeforeUpgrade != "" {
+		args = append(args, "--before-upgrade", p.Target.BeforeUpgrade)
+	}
+	if p.Target.AfterUpgrade != "" {
+		args = append(args, "--after-upgrade", p.Target.AfterUpgrade)
+	}
+	args = append(args, p.templateValueArgs()...)
+
+	// special flags for the "deb" target mode
+	if p.Target.Mode == "deb" {
+		if changelog := p.changelogFile(); changelog != "" {
+			args = append(args, "--deb-changelog", changelog)
+		}
+		for _, s := range p.Target.Systemd {
+			args = append(args, "--deb-systemd", s)
+		}
+		for _, f := range p.buildFields {
+			args = append(args, "--deb-field", f)
+		}
+		args = append(args, p.triggerArgs()...)
+		if p.Target.Dist != "" {
+			args = append(args, "--deb-dist", p.Target.Dist)
+		}
+		if p.ldconfig {
+			args = append(args, "--deb-activate-noawait", "ldconfig")
+		}
+		if p.Target.NoDefaultConfigFiles {
+			args = append(args, "--deb-no-default-config-files")
+		}
+		if p.Target.Priority != "" {
+			args = append(args, "--deb-priority", p.Target.Priority)
+		}
+		if p.Target.UpstreamChangelog != "" {
+			args = append(args, "--deb-upstream-changelog", p.Target.UpstreamChangelog)
+		}
+		if p.Target.CustomControl != "" {
+			args = append(args, "--deb-custom-control", p.Target.CustomControl)
+		}
+		for _, m := range p.Target.MetaFiles {
+			args = append(args, "--deb-meta-file", m)
+		}
+		if p.Target.User != "" {
+			args = append(args, "--deb-user", p.Target.User)
+		}
+		if p.Target.Group != "" {
+			args = append(args, "--deb-group", p.Target.Group)
+		}
+		// fpm names zstd compression by the file extension zst
+		if p.Target.Compression == "zstd" {
+			args = append(args, "--deb-compression", "zst")
+		} else if p.Target.Compression != "" {
+			args = append(args, "--deb-compression", p.Target.Compression)
+		}
+
+		// sorted, so the arguments do not change between runs
+		fields := []string{}
+		for name := range p.Target.Fields {
+			fields = append(fields, name)
+		}
+		sort.Strings(fields)
+		for _, name := range fields {
+			args = append(args, "--deb-field", fmt.Sprintf("%s: %s", name, p.Target.Fields[name]))
+		}
+		for _, s := range p.Target.Suggests {
+			args = append(args, "--deb-suggests", s)
+		}
+		for _, b := range p.Target.Breaks {
+			args = append(args, "--deb-breaks", b)
+		}
+		for _, r := range p.Target.Recommends {
+			args = append(args, "--deb-recommends", r)
+		}
+		for _, d := range p.Target.PreDepends {
+			args = append(args, "--deb-pre-depends", d)
+		}
+		for _, d := range p.Target.BuildDepends {
+			args = append(args, "--deb-build-depends", d)
+		}
+
+		// handle systemd units
+		if p.Target.SystemdEnable == true {
+			args = append(args, "--deb-systemd-enable")
+		}
+		if p.Target.SystemdAutoStart == true {
+			args = append(args, "--deb-systemd-auto-start")
+		}
+		if p.Target.SystemdRestartAfterUpgrade == true {
+			args = append(args, "--deb-systemd-restart-after-upgrade")
+		}
+	}
+
+	// special flags for the "pacman" target mode
+	if p.Target.Mode == "pacman" {
+		for _, o := range p.Target.OptDepends {
+			args = append(args, "--pacman-optional-depends", o)
+		}
+	}
+
+	// special flags for the "tar" target mode
+	// fpm compresses tar archives according to the extension of the output file
+	if p.Target.Mode == "tar" && p.Target.OutputPath == "" {
+		output := fmt.Sprintf("%s_%s.tar", p.Name, p.Target.Version)
+		switch p.Target.Compression {
+		case "", "gz":
+			output += ".gz"
+		case "xz", "bz2":
+			output += "." + p.Target.Compression
+		}
+		args = append(args, "-p", output)
+	}
+
+	// special flags for the "freebsd" target mode
+	if p.Target.Mode == "freebsd" {
+		if p.Target.FreeBSDOrigin != "" {
+			args = append(args, "--freebsd-origin", p.Target.FreeBSDOrigin)
+		}
+		if p.Target.FreeBSDOSVersion != "" {
+			args = append(args, "--freebsd-osversion", p.Target.FreeBSDOSVersion)
+		}
+	}
+
+	// special flags for the "osxpkg" target mode
+	if p.Target.Mode == "osxpkg" {
+		if p.Target.OSXPkgIdentifierPrefix != "" {
+			args = append(args, "--osxpkg-identifier-prefix", p.Target.OSXPkgIdentifierPrefix)
+		}
+		if p.Target.OSXPkgOwnership != "" {
+			args = append(args, "--osxpkg-ownership", p.Target.OSXPkgOwnership)
+		}
+	}
+
+	// special flags for the "sh" target mode
+	// the installer runs the after install script once the files are extracted
+	if p.Target.Mode == "sh" && p.Target.PostExtract != "" {
+		args = append(args, "--after-install", p.Target.PostExtract)
+	}
+
+	// special flags for the "dir" target mode
+	if p.Target.Mode == "dir" {
+		output := p.Target.OutputDir
+		if output == "" {
+			output = fmt.Sprintf("%s_%s", p.Name, p.Target.Version)
+		}
+		args = append(args, "-p", output)
+	}
+
+	// special flags for the "rpm" target mode
+	if p.Target.Mode == "rpm" {
+		if changelog := p.changelogFile(); changelog != "" {
+			args = append(args, "--rpm-changelog", changelog)
+		}
+		if p.Target.Summary != "" {
+			args = append(args, "--rpm-summary", p.Target.Summary)
+		}
+		if p.Target.Dist != "" {
+			args = append(args, "--rpm-dist", p.Target.Dist)
+		}
+		if p.Source.PreserveOwnership {
+			args = append(args, "--rpm-use-file-permissions")
+		}
+		if p.Target.User != "" {
+			args = append(args, "--rpm-user", p.Target.User)
+		}
+		if p.Target.Group != "" {
+			args = append(args, "--rpm-group", p.Target.Group)
+		}
+		for _, a := range p.rpmAttributes {
+			args = append(args, "--rpm-attr", a)
+		}
+	}
+
+	// program arguments of pleaserun may look like flags
+	if p.Source.Mode == "pleaserun" {
+		args = append(args, "--")
+	}
+
+	// append arguments
+	for _, a := range paths {
+		args = append(args, a)
+	}
+
+	return args
+}
+
 // main method
 func main() {
-	c := FPMConfig{}
+	c := FPMConfig{report: Report{RunID: newRunID()}}
+
+	// mask secrets in everything printed, including the output of fpm
+	if err := redaction.start(); err != nil {
+		fmt.Printf(tr("could not redact output: %s\n"), err)
+		os.Exit(1)
+	}
+	defer redaction.close()
+	redaction.addEnv(secretEnv...)
+
+	// the first argument selects the command, default is to build all packages
+	command := "build"
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		command = os.Args[1]
+	}
+
+	// flags follow the command
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	golden := flags.String("golden", "", "compare the fpm invocations with the golden files in this directory instead of building")
+	updateGolden := flags.Bool("update-golden", false, "overwrite the golden files with the current fpm invocations")
+	dryRun := flags.Bool("dry-run", false, "build the packages but only print where they would be published to")
+	verifyCredentials := flags.Bool("verify-credentials", false, "verify the access to all publish targets before publishing")
+	graphFormat := flags.String("format", "mermaid", "format of the graph rendered by command graph: dot|mermaid")
+	graphOutput := flags.String("output", "", "file the graph rendered by command graph is written to")
+	manifest := flags.String("manifest", "train.yml", "manifest of the release train run by command train")
+	appendChangelog := flags.String("append-changelog", "", "rebuild with a bumped iteration and this message as additional changelog entry")
+	bench := flags.Int("bench", 0, "build all packages this many times and print how long every stage took, nothing is published")
+	tui := flags.Bool("tui", false, "show the progress of every package and the latest log lines when running locally")
+	configKeyFlag := flags.String("config-key", "", "public key packages.yml has to be signed with, a file or the armored key")
+	lock := flags.Bool("lock", false, "write the versions of all tools and the digests of remote sources to packages.lock")
+	locked := flags.Bool("locked", false, "fail if tools or remote sources differ from packages.lock")
+	logLevel := flags.String("log-level", "", "log level of fpm for all packages: error|warn|info|debug, overrides log_level in packages.yml")
+	languageFlag := flags.String("language", "", "language of the messages: en|de, overrides language in packages.yml")
+	configSignature := flags.String("config-signature", "", "detached signature of packages.yml, defaults to packages.yml.sig or packages.yml.asc")
+	if len(os.Args) > 1 {
+		args := os.Args[1:]
+		if command == os.Args[1] {
+			args = os.Args[2:]
+		}
+		flags.Parse(args)
+	}
+
+	// messages are printed in the language of the user until packages.yml is read
+	language = environmentLanguage()
+	if *languageFlag != "" {
+		if err := selectLanguage(*languageFlag, "--language"); err != nil {
+			fmt.Printf(err.Error())
+			exit(1)
+		}
+	}
+
+	// protected pipelines only build configurations signed by a trusted key
+	// a release train is driven by its manifest, the configurations listed in it are verified when they are loaded
+	if configKeyConfigured(*configKeyFlag) {
+		config := "packages.yml"
+		if command == "train" {
+			config = *manifest
+		}
+		if err := verifyConfig(config, *configKeyFlag, *configSignature); err != nil {
+			fmt.Printf("%s\n", err)
+			exit(1)
+		}
+	}
+
+	// a release train reads its own manifest and all configurations listed in it
+	if command == "train" {
+		runTrain(*manifest, *configKeyFlag, *dryRun, *verifyCredentials)
+	}
 
-	if err := c.ReadFile("packages.yml"); err != nil {
+	readErr := c.ReadFile("packages.yml")
+	c.applyLogLevel(*logLevel, true)
+	if *languageFlag == "" && c.Language != "" {
+		if err := selectLanguage(c.Language, "language"); err != nil {
+			fmt.Printf(err.Error())
+			exit(1)
+		}
+	}
+
+	// cached packages are keyed by the configuration and everything else changing the packages
+	cacheInput, _ := ioutil.ReadFile("packages.yml")
+	cacheInput = append(cacheInput, *appendChangelog...)
+	c.collectSecrets()
+	if readErr != nil {
+		fmt.Printf(readErr.Error())
+	}
+
+	// timestamps and sorting must not depend on the runner
+	if err := c.applyLocale(); err != nil {
 		fmt.Printf(err.Error())
+		exit(1)
 	}
 
-	if err := c.check(); err != nil {
+	if err := c.applyGitOverrides(); err != nil {
 		fmt.Printf(err.Error())
-		os.Exit(1)
+		exit(1)
+	}
+
+	if err := c.applyDispatch(); err != nil {
+		fmt.Printf(err.Error())
+		exit(1)
+	}
+
+	if err := c.addKeyring(); err != nil {
+		fmt.Printf(tr("could not create keyring package: %s\n"), err)
+		exit(1)
+	}
+
+	// resolve references between packages once all versions are known
+	if err := c.resolveReferences(); err != nil {
+		fmt.Printf(err.Error())
+		exit(1)
 	}
 
-	if err := c.build(); err != nil {
+	// descriptions are kept in sync with the documentation
+	if err := c.applyReadmeDescriptions(); err != nil {
 		fmt.Printf(err.Error())
+		exit(1)
+	}
+
+	c.order()
+	checkErr := c.check()
+
+	// the doctor reports configuration errors along with problems of the environment
+	if command == "doctor" {
+		if checkErr == nil {
+			checkErr = readErr
+		}
+		if !printDiagnoses(c.doctor(checkErr)) {
+			exit(1)
+		}
+		return
+	}
+
+	if checkErr != nil {
+		fmt.Printf(checkErr.Error())
+		c.finish(1)
+	}
+
+	switch command {
+	case "build":
+		if err := c.appendChangelog(*appendChangelog); err != nil {
+			fmt.Printf(tr("could not append changelog entry: %s\n"), err)
+			c.finish(1)
+		}
+		if *golden != "" {
+			if err := c.golden(*golden, *updateGolden); err != nil {
+				fmt.Printf("%s\n", err)
+				c.finish(1)
+			}
+			c.finish(0)
+		}
+	case "restore":
+		// restore the packages built by a previous job of the workflow
+		if c.Cache == nil {
+			fmt.Printf(tr("command restore requires the key cache in packages.yml\n"))
+			c.finish(1)
+		}
+		restored, err := c.restoreCache(cacheInput)
+		if err != nil || !restored {
+			fmt.Printf(tr("could not restore the packages from the cache: %v\n"), err)
+			c.finish(1)
+		}
+		if err := c.writeReport(); err != nil {
+			fmt.Printf(tr("could not write report: %s\n"), err)
+			c.finish(3)
+		}
+		c.finish(0)
+	case "graph":
+		// render the relationships of the packages without building them
+		if err := c.graph(*graphFormat, *graphOutput); err != nil {
+			fmt.Printf("%s\n", err)
+			c.finish(1)
+		}
+		c.finish(0)
+	case "promote":
+		// promote packages published to the quarantine suite by a previous run
+		if err := c.promote(); err != nil {
+			fmt.Printf(tr("promotion failed: %s\n"), err)
+			c.finish(4)
+		}
+		c.finish(0)
+	default:
+		fmt.Printf(tr("unknown command %s, valid commands are build|promote|restore|graph|doctor|train\n"), command)
+		c.finish(1)
+	}
+
+	// build only the packages affected by the pull request
+	if err := c.applySelective("packages.yml"); err != nil {
+		fmt.Printf("%s\n", err)
+		c.finish(1)
+	}
+
+	// make sure the packages are built from the commit that triggered the workflow
+	if err := c.verifyWorkspace(); err != nil {
+		fmt.Printf("%s\n", err)
+		c.finish(2)
+	}
+
+	// record the environment for reproducing the packages later
+	if err := c.captureEnvironment("packages.yml"); err != nil {
+		fmt.Printf(tr("could not record the build environment: %s\n"), err)
+		c.finish(1)
+	}
+	c.applyExpiry()
+
+	// the lockfile pins the tools and remote sources of reproducible builds
+	if *lock || *locked {
+		if err := c.startLock("packages.yml", *locked); err != nil {
+			fmt.Printf("%s\n", err)
+			c.finish(1)
+		}
+	}
+
+	// the terminal view is meant for local runs, the logs of workflow runs stay plain
+	if *tui && os.Getenv("GITHUB_ACTIONS") != "true" {
+		logPath := filepath.Join(os.TempDir(), fmt.Sprintf("action-package-%s.log", c.report.RunID))
+		var err error
+		if ui, err = startUI(redaction.console, logPath, c.Packages); err != nil {
+			fmt.Printf(tr("could not start the terminal view: %s\n"), err)
+		}
+	}
+
+	// packages built by a previous job of the workflow are restored instead of rebuilt
+	restored := false
+	if c.Cache != nil {
+		var err error
+		if restored, err = c.restoreCache(cacheInput); err != nil {
+			fmt.Printf(tr("::warning::could not restore the packages from the cache: %s\n"), err)
+		}
+	}
+
+	if !restored {
+		// packages of the same run must not overwrite each others files on the hosts
+		if err := c.checkConflicts(); err != nil {
+			fmt.Printf("%s", err)
+			c.finish(2)
+		}
+
+		if *bench > 0 {
+			if err := c.benchmark(*bench); err != nil {
+				fmt.Printf(err.Error())
+				c.finish(2)
+			}
+			if err := c.writeReport(); err != nil {
+				fmt.Printf(tr("could not write report: %s\n"), err)
+				c.finish(3)
+			}
+			c.finish(0)
+		}
+
+		if err := c.build(); err != nil {
+			fmt.Printf(err.Error())
+		}
+		if *lock {
+			if err := c.writeLock(); err != nil {
+				fmt.Printf(tr("could not write %s: %s\n"), lockFile, err)
+				c.finish(3)
+			}
+		}
+		if c.Cache != nil {
+			if err := c.saveCache(cacheInput); err != nil {
+				fmt.Printf(tr("::warning::could not save the packages to the cache: %s\n"), err)
+			}
+		}
+	}
+
+	// the contents of restored packages are not staged, release notes are created by the building job
+	if c.ReleaseNotes != nil && !restored {
+		if err := c.releaseNotes(); err != nil {
+			fmt.Printf(tr("could not create release notes: %s\n"), err)
+			c.finish(3)
+		}
+	}
+
+	if err := c.writeExpiryIndex(); err != nil {
+		fmt.Printf(tr("could not write the expiry index: %s\n"), err)
+		c.finish(3)
+	}
+
+	if c.Signing != nil {
+		if err := c.Signing.signReleaseFiles(); err != nil {
+			fmt.Printf(tr("could not sign release files: %s\n"), err)
+			c.finish(3)
+		}
+	}
+
+	// publish before writing the report so the results of all publish targets are included
+	published := c.publish(*dryRun, *verifyCredentials)
+
+	if err := c.writeReport(); err != nil {
+		fmt.Printf(tr("could not write report: %s\n"), err)
+		c.finish(3)
+	}
+
+	if published != nil {
+		fmt.Printf("%s\n", published)
+		c.finish(4)
 	}
 
+	c.finish(0)
 }
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// packagecloudPublisher publishes packages to packagecloud.io
+type packagecloudPublisher struct {
+	target *PublishTarget
+}
+
+// method request sends a request to the packagecloud API and decodes the json response into result
+// the API token is sent as user name of basic authentication
+func (pc *packagecloudPublisher) request(method, path, contentType string, body io.Reader, result interface{}) error {
+	base := pc.target.URL
+	if base == "" {
+		base = "https://packagecloud.io"
+	}
+	req, err := http.NewRequest(method, strings.TrimSuffix(base, "/")+"/api/v1"+path, body)
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.SetBasicAuth(pc.target.Password, "")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	contents, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("packagecloud %s %s failed: %s %s", method, path, resp.Status, contents)
+	}
+	if result != nil {
+		return json.Unmarshal(contents, result)
+	}
+	return nil
+}
+
+// method distroVersionID looks up the id packagecloud uses for a distribution like "ubuntu/focal"
+func (pc *packagecloudPublisher) distroVersionID(distribution string) (int, error) {
+	distributions := map[string][]struct {
+		IndexName string `json:"index_name"`
+		Versions  []struct {
+			ID        int    `json:"id"`
+			IndexName string `json:"index_name"`
+		} `json:"versions"`
+	}{}
+	if err := pc.request(http.MethodGet, "/distributions.json", "", nil, &distributions); err != nil {
+		return 0, err
+	}
+
+	parts := strings.SplitN(distribution, "/", 2)
+	for _, distros := range distributions {
+		for _, d := range distros {
+			if d.IndexName != parts[0] {
+				continue
+			}
+			for _, v := range d.Versions {
+				if v.IndexName == parts[1] {
+					return v.ID, nil
+				}
+			}
+		}
+	}
+	return 0, fmt.Errorf("unknown packagecloud distribution %s", distribution)
+}
+
+// method Publish uploads a package to the repository of the suite
+func (pc *packagecloudPublisher) Publish(artifact string, s Suite) error {
+	id, err := pc.distroVersionID(s.Distribution)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(artifact)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	body := &bytes.Buffer{}
+	form := multipart.NewWriter(body)
+	if err := form.WriteField("package[distro_version_id]", fmt.Sprint(id)); err != nil {
+		return err
+	}
+	part, err := form.CreateFormFile("package[package_file]", filepath.Base(artifact))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, f); err != nil {
+		return err
+	}
+	if err := form.Close(); err != nil {
+		return err
+	}
+
+	return pc.request(http.MethodPost, fmt.Sprintf("/repos/%s/packages.json", s.Repo), form.FormDataContentType(), body, nil)
+}
+
+// method Describe implements Publisher
+func (pc *packagecloudPublisher) Describe(artifact string, s Suite) string {
+	base := pc.target.URL
+	if base == "" {
+		base = "https://packagecloud.io"
+	}
+	return fmt.Sprintf("upload to %s/%s as %s, packagecloud rejects a package whose file name already exists",
+		strings.TrimSuffix(base, "/"), s.Repo, s.Distribution)
+}
+
+// method Check implements Publisher
+// reading the repository verifies the token, the distribution is resolved like for uploads
+func (pc *packagecloudPublisher) Check(s Suite) error {
+	if err := pc.request(http.MethodGet, fmt.Sprintf("/repos/%s.json", s.Repo), "", nil, nil); err != nil {
+		return err
+	}
+	_, err := pc.distroVersionID(s.Distribution)
+	return err
+}
+
+// function packageArchitecture returns the architecture as named by fpm in packages of a mode
+// fpm builds for the runner by default and translates names between deb and rpm e.g. amd64 to x86_64
+func packageArchitecture(mode, arch string) string {
+	if arch == "" {
+		arch = "amd64"
+	}
+	names := map[string]string{"x86_64": "amd64", "aarch64": "arm64", "noarch": "all"}
+	if mode == "rpm" {
+		names = map[string]string{"amd64": "x86_64", "arm64": "aarch64", "all": "noarch"}
+	}
+	if name, ok := names[arch]; ok {
+		return name
+	}
+	return arch
+}
+
+// method Promote copies a package from one repository to another and removes it from the first
+// packagecloud only promotes between repositories, the distribution of both suites has to be the same
+func (pc *packagecloudPublisher) Promote(p *Package, from, to Suite) error {
+	if from.Distribution != to.Distribution {
+		return fmt.Errorf("packagecloud can not promote packages between distributions")
+	}
+
+	packages := []struct {
+		Filename string `json:"filename"`
+		Version  string `json:"version"`
+	}{}
+	arch := packageArchitecture(p.Target.Mode, p.Target.Architecture)
+	path := fmt.Sprintf("/repos/%s/package/%s/%s/%s/%s/versions.json", from.Repo, p.Target.Mode, from.Distribution, url.PathEscape(p.Name), url.PathEscape(arch))
+	if err := pc.request(http.MethodGet, path, "", nil, &packages); err != nil {
+		return err
+	}
+
+	for _, pkg := range packages {
+		if pkg.Version != p.Target.Version {
+			continue
+		}
+		form := url.Values{"destination": {to.Repo}}
+		promote := fmt.Sprintf("/repos/%s/%s/%s/promote.json", from.Repo, from.Distribution, url.PathEscape(pkg.Filename))
+		return pc.request(http.MethodPost, promote, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()), nil)
+	}
+	return fmt.Errorf("package %s %s not found in %s", p.Name, p.Target.Version, from.Repo)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"unicode"
+)
+
+// PathPolicy decides how unusual file names and special files in the source tree are handled
+// every policy is "allow" (packaged as is), "skip" (the file is excluded from the package) or "fail"
+type PathPolicy struct {
+	// Spaces handles names containing whitespace, defaults to "allow"
+	Spaces string `yaml:"spaces"`
+
+	// Unicode handles names containing non-ASCII characters, defaults to "allow"
+	Unicode string `yaml:"unicode"`
+
+	// LongPaths handles installed paths longer than MaxLength, defaults to "allow"
+	LongPaths string `yaml:"long_paths"`
+	// MaxLength of an installed path in bytes, defaults to 255
+	MaxLength int `yaml:"max_length"`
+
+	// SymlinkCycles handles symlinks that can not be resolved because they form a cycle, defaults to "fail"
+	SymlinkCycles string `yaml:"symlink_cycles"`
+
+	// BrokenSymlinks handles symlinks whose target does not exist, defaults to "allow"
+	// links to files of other packages are broken on the build host but fine on the target host
+	BrokenSymlinks string `yaml:"broken_symlinks"`
+
+	// Sockets handles unix sockets, defaults to "skip"
+	Sockets string `yaml:"sockets"`
+
+	// FIFOs handles named pipes, defaults to "skip"
+	FIFOs string `yaml:"fifos"`
+}
+
+// valid policies for unusual file names
+var pathPolicies = []string{"allow", "skip", "fail"}
+
+// method check validates the path policy of the package
+func (pp *PathPolicy) check(name string) error {
+	for _, f := range []struct{ field, policy string }{
+		{"path_policy.spaces", pp.Spaces},
+		{"path_policy.unicode", pp.Unicode},
+		{"path_policy.long_paths", pp.LongPaths},
+		{"path_policy.symlink_cycles", pp.SymlinkCycles},
+		{"path_policy.broken_symlinks", pp.BrokenSymlinks},
+		{"path_policy.sockets", pp.Sockets},
+		{"path_policy.fifos", pp.FIFOs},
+	} {
+		if f.policy != "" && !contains(pathPolicies, f.policy) {
+			return ConfigError{
+				packageEntry: name,
+				field:        f.field,
+				message:      fmt.Sprintf(tr("policy may contain %s"), strings.Join(pathPolicies, "|")),
+			}
+		}
+	}
+	if pp.MaxLength < 0 {
+		return ConfigError{
+			packageEntry: name,
+			field:        "path_policy.max_length",
+			message:      "the maximal length must be positive",
+		}
+	}
+	return nil
+}
+
+// function policy returns the configured policy or the default
+func policy(configured, fallback string) string {
+	if configured == "" {
+		return fallback
+	}
+	return configured
+}
+
+// function escapePattern escapes a path so fpm matches it literally as exclude pattern
+func escapePattern(path string) string {
+	r := strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `{`, `\{`)
+	return r.Replace(path)
+}
+
+// method applyPathPolicy checks the names of all files of the package
+//
+// names containing control characters always fail as package managers can not list them
+// the returned exclude patterns remove the skipped files from the package
+func (p *Package) applyPathPolicy() (excludes []string, warnings []string, err error) {
+	pp := *p.PathPolicy
+	maxLength := pp.MaxLength
+	if maxLength == 0 {
+		maxLength = 255
+	}
+
+	files, err := p.contents()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, f := range files {
+		if strings.IndexFunc(f.Target, unicode.IsControl) >= 0 {
+			return nil, nil, fmt.Errorf("the name of %q contains control characters", f.Target)
+		}
+
+		violations := []struct{ violation, action string }{}
+		if strings.IndexFunc(f.Target, unicode.IsSpace) >= 0 {
+			violations = append(violations, struct{ violation, action string }{
+				"contains whitespace", policy(pp.Spaces, "allow")})
+		}
+		if strings.IndexFunc(f.Target, func(r rune) bool { return r > unicode.MaxASCII }) >= 0 {
+			violations = append(violations, struct{ violation, action string }{
+				"contains non-ASCII characters", policy(pp.Unicode, "allow")})
+		}
+		if len(f.Target) > maxLength {
+			violations = append(violations, struct{ violation, action string }{
+				fmt.Sprintf("is longer than %d bytes", maxLength), policy(pp.LongPaths, "allow")})
+		}
+		if f.Info.Mode()&os.ModeSymlink != 0 {
+			_, err := os.Stat(f.Source)
+			switch {
+			case errors.Is(err, syscall.ELOOP):
+				violations = append(violations, struct{ violation, action string }{
+					"is a symlink cycle", policy(pp.SymlinkCycles, "fail")})
+			case os.IsNotExist(err):
+				violations = append(violations, struct{ violation, action string }{
+					"is a broken symlink", policy(pp.BrokenSymlinks, "allow")})
+			}
+		}
+		if f.Info.Mode()&os.ModeSocket != 0 {
+			violations = append(violations, struct{ violation, action string }{
+				"is a socket", policy(pp.Sockets, "skip")})
+		}
+		if f.Info.Mode()&os.ModeNamedPipe != 0 {
+			violations = append(violations, struct{ violation, action string }{
+				"is a named pipe", policy(pp.FIFOs, "skip")})
+		}
+
+		skip := false
+		for _, v := range violations {
+			switch v.action {
+			case "fail":
+				return nil, nil, fmt.Errorf("%q %s", f.Target, v.violation)
+			case "skip":
+				warnings = append(warnings, fmt.Sprintf("skipped %q, it %s", f.Target, v.violation))
+				skip = true
+			}
+		}
+		if skip {
+			excludes = append(excludes, escapePattern(strings.TrimPrefix(f.Target, "/")))
+		}
+	}
+	return excludes, warnings, nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEscapePattern(t *testing.T) {
+	for _, tc := range []struct{ name, path, want string }{
+		{"plain", "usr/bin/tool", "usr/bin/tool"},
+		{"spaces", "usr/share/my app/read me.txt", "usr/share/my app/read me.txt"},
+		{"unicode", "usr/share/docs/größe-日本.txt", "usr/share/docs/größe-日本.txt"},
+		{"long", strings.Repeat("a", 300), strings.Repeat("a", 300)},
+		{"wildcards", `opt/[x]*?{y}\z`, `opt/\[x]\*\?\{y}\\z`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := escapePattern(tc.path); got != tc.want {
+				t.Errorf("escapePattern(%q) = %q, want %q", tc.path, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestApplyPathPolicy(t *testing.T) {
+	long := strings.Repeat("l", 200)
+	for _, tc := range []struct {
+		name     string
+		create   func(t *testing.T, dir string)
+		policy   PathPolicy
+		excludes []string
+		fails    bool
+	}{
+		{
+			name:   "spaces allowed",
+			create: func(t *testing.T, dir string) { writeFile(t, filepath.Join(dir, "read me.txt")) },
+		},
+		{
+			name:     "spaces skipped",
+			create:   func(t *testing.T, dir string) { writeFile(t, filepath.Join(dir, "read me.txt")) },
+			policy:   PathPolicy{Spaces: "skip"},
+			excludes: []string{"opt/app/read me.txt"},
+		},
+		{
+			name:   "unicode failed",
+			create: func(t *testing.T, dir string) { writeFile(t, filepath.Join(dir, "größe-日本.txt")) },
+			policy: PathPolicy{Unicode: "fail"},
+			fails:  true,
+		},
+		{
+			name:     "unicode skipped",
+			create:   func(t *testing.T, dir string) { writeFile(t, filepath.Join(dir, "größe-日本.txt")) },
+			policy:   PathPolicy{Unicode: "skip"},
+			excludes: []string{"opt/app/größe-日本.txt"},
+		},
+		{
+			name:   "long path below the default length",
+			create: func(t *testing.T, dir string) { writeFile(t, filepath.Join(dir, long)) },
+			policy: PathPolicy{LongPaths: "fail"},
+		},
+		{
+			name: "long path above the default length",
+			create: func(t *testing.T, dir string) {
+				writeFile(t, filepath.Join(dir, long[:100], long))
+			},
+			policy: PathPolicy{LongPaths: "fail"},
+			fails:  true,
+		},
+		{
+			name:     "long path above max_length skipped",
+			create:   func(t *testing.T, dir string) { writeFile(t, filepath.Join(dir, long)) },
+			policy:   PathPolicy{LongPaths: "skip", MaxLength: 100},
+			excludes: []string{"opt/app/" + long},
+		},
+		{
+			name:   "symlink cycle fails by default",
+			create: symlinkCycle,
+			fails:  true,
+		},
+		{
+			name:     "symlink cycle skipped",
+			create:   symlinkCycle,
+			policy:   PathPolicy{SymlinkCycles: "skip"},
+			excludes: []string{"opt/app/a", "opt/app/b"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tc.create(t, dir)
+
+			p := &Package{Name: "test", Paths: []string{dir + "=/opt/app"}, PathPolicy: &tc.policy}
+			p.Source.Mode = "dir"
+
+			type result struct {
+				excludes []string
+				err      error
+			}
+			done := make(chan result, 1)
+			go func() {
+				excludes, _, err := p.applyPathPolicy()
+				done <- result{excludes, err}
+			}()
+
+			var r result
+			select {
+			case r = <-done:
+			case <-time.After(10 * time.Second):
+				t.Fatal("applyPathPolicy did not return")
+			}
+			if tc.fails {
+				if r.err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if r.err != nil {
+				t.Fatal(r.err)
+			}
+			if !reflect.DeepEqual(r.excludes, tc.excludes) {
+				t.Errorf("excludes = %q, want %q", r.excludes, tc.excludes)
+			}
+		})
+	}
+}
+
+// function writeFile creates an empty file and its parent directories
+func writeFile(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// function symlinkCycle creates the links a -> b and b -> a
+func symlinkCycle(t *testing.T, dir string) {
+	t.Helper()
+	for _, l := range [][2]string{{"b", "a"}, {"a", "b"}} {
+		if err := os.Symlink(l[0], filepath.Join(dir, l[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
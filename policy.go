@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// policyEnv names the environment variable pointing to an organization wide policy file
+// platform teams set it on shared runners, so the policy applies regardless of packages.yml
+const policyEnv = "PACKAGE_POLICY"
+
+// Policy restricts which packages may be built, it is enforced when the configuration is validated
+type Policy struct {
+	// NamePrefix every package name has to start with *OPTIONAL*
+	NamePrefix string `yaml:"name_prefix"`
+
+	// NameSuffix every package name has to end with *OPTIONAL*
+	NameSuffix string `yaml:"name_suffix"`
+
+	// ForbiddenNames are patterns of package names that must not be built e.g. "libc*" *OPTIONAL*
+	ForbiddenNames []string `yaml:"forbidden_names"`
+
+	// MaintainerDomains are the email domains a maintainer is accepted from *OPTIONAL*
+	// if set, every package needs a maintainer like "Team <team@example.com>"
+	MaintainerDomains []string `yaml:"maintainer_domains"`
+
+	// Vendors are the accepted vendors, if set every package needs one of them *OPTIONAL*
+	Vendors []string `yaml:"vendors"`
+}
+
+// function readPolicy reads the policy file named by PACKAGE_POLICY, it returns nil if the variable is unset
+func readPolicy() (*Policy, error) {
+	path := os.Getenv(policyEnv)
+	if path == "" {
+		return nil, nil
+	}
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p := &Policy{}
+	if err := yaml.UnmarshalStrict(contents, p); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return p, nil
+}
+
+// function maintainerDomain returns the domain of the email address of a maintainer
+func maintainerDomain(maintainer string) string {
+	address := maintainer
+	if start := strings.LastIndex(maintainer, "<"); start >= 0 {
+		address = strings.TrimSuffix(strings.TrimSpace(maintainer[start+1:]), ">")
+	}
+	at := strings.LastIndex(address, "@")
+	if at < 0 {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(address[at+1:]))
+}
+
+// method enforce validates a package against the policy, source names where the policy comes from
+func (pol *Policy) enforce(p *Package, source string) error {
+	violation := func(field, message string) error {
+		return ConfigError{
+			packageEntry: p.Name,
+			field:        field,
+			message:      fmt.Sprintf(tr("%s (policy of %s)"), message, source),
+		}
+	}
+
+	if !strings.HasPrefix(p.Name, pol.NamePrefix) {
+		return violation("name", fmt.Sprintf(tr("package names have to start with %q"), pol.NamePrefix))
+	}
+	if !strings.HasSuffix(p.Name, pol.NameSuffix) {
+		return violation("name", fmt.Sprintf(tr("package names have to end with %q"), pol.NameSuffix))
+	}
+	for _, f := range pol.ForbiddenNames {
+		if ok, _ := filepath.Match(f, p.Name); ok {
+			return violation("name", fmt.Sprintf(tr("package names matching %q must not be built"), f))
+		}
+	}
+	if len(pol.MaintainerDomains) > 0 {
+		domain := maintainerDomain(p.Target.Maintainer)
+		if !contains(pol.MaintainerDomains, domain) {
+			return violation("target.maintainer", fmt.Sprintf(
+				tr("the maintainer needs an email address of %s"), strings.Join(pol.MaintainerDomains, "|")))
+		}
+	}
+	if len(pol.Vendors) > 0 && !contains(pol.Vendors, p.Target.Vendor) {
+		return violation("target.vendor", fmt.Sprintf(
+			tr("the vendor has to be one of %s"), strings.Join(pol.Vendors, "|")))
+	}
+	return nil
+}
+
+// method checkPolicies enforces the policy of packages.yml and the policy file of the runner
+func (c *FPMConfig) checkPolicies() error {
+	runner, err := readPolicy()
+	if err != nil {
+		return ConfigError{
+			field:   policyEnv,
+			message: fmt.Sprintf(tr("could not read the policy file: %s"), err),
+		}
+	}
+	for i := range c.Packages {
+		if c.Policy != nil {
+			if err := c.Policy.enforce(&c.Packages[i], "packages.yml"); err != nil {
+				return err
+			}
+		}
+		if runner != nil {
+			if err := runner.enforce(&c.Packages[i], os.Getenv(policyEnv)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Provenance records the commit the packages were built from
+type Provenance struct {
+	// Commit is the commit checked out in the workspace
+	Commit string `json:"commit"`
+
+	// Expected is the commit that triggered the workflow, taken from GITHUB_SHA
+	Expected string `json:"expected,omitempty"`
+
+	// Dirty lists the modified and untracked files of the workspace
+	Dirty []string `json:"dirty,omitempty"`
+}
+
+// function workspaceGit runs a git command in the workspace and returns its trimmed output
+// the workspace is mounted into the container with a different owner, so it is marked as safe
+func workspaceGit(args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-c", "safe.directory=*"}, args...)...)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s failed: %s", args[0], err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// method verifyWorkspace compares the checked out commit with GITHUB_SHA and records it in the report
+//
+// a mismatch or uncommitted changes are reported as warnings, with require_clean_tree they refuse the build
+// workspaces that are not git repositories are only refused with require_clean_tree
+func (c *FPMConfig) verifyWorkspace() error {
+	commit, err := workspaceGit("rev-parse", "HEAD")
+	if err != nil {
+		if c.RequireCleanTree {
+			return fmt.Errorf("could not determine the commit of the workspace: %s", err)
+		}
+		return nil
+	}
+	status, err := workspaceGit("status", "--porcelain")
+	if err != nil {
+		return err
+	}
+
+	p := &Provenance{Commit: commit, Expected: os.Getenv("GITHUB_SHA")}
+	if status != "" {
+		for _, line := range strings.Split(status, "\n") {
+			// lines start with the status of the file e.g. " M" or "??"
+			fields := strings.SplitN(strings.TrimSpace(line), " ", 2)
+			p.Dirty = append(p.Dirty, strings.TrimSpace(fields[len(fields)-1]))
+		}
+	}
+	c.report.Provenance = p
+
+	problems := []string{}
+	if p.Expected != "" && p.Expected != p.Commit {
+		problems = append(problems, fmt.Sprintf("the workspace is at %s but GITHUB_SHA is %s", p.Commit, p.Expected))
+	}
+	if len(p.Dirty) > 0 {
+		problems = append(problems, fmt.Sprintf("the workspace has uncommitted changes: %s", strings.Join(p.Dirty, ", ")))
+	}
+	if len(problems) == 0 {
+		fmt.Printf(tr("building from commit %s\n"), p.Commit)
+		return nil
+	}
+	if c.RequireCleanTree {
+		return fmt.Errorf("refusing to build: %s", strings.Join(problems, ", "))
+	}
+	for _, problem := range problems {
+		fmt.Printf("::warning::%s\n", problem)
+	}
+	return nil
+}
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// PublishTargets is the list of targets packages are published to
+// a single target may be given without wrapping it in a list
+type PublishTargets []PublishTarget
+
+// method UnmarshalYAML accepts a single publish target as well as a list of targets
+func (t *PublishTargets) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	single := PublishTarget{}
+	if err := unmarshal(&single); err == nil {
+		*t = PublishTargets{single}
+		return nil
+	}
+	list := []PublishTarget{}
+	if err := unmarshal(&list); err != nil {
+		return err
+	}
+	*t = list
+	return nil
+}
+
+// PublishTarget describes where built packages are published to
+type PublishTarget struct {
+	// Name identifies the target in logs and the report *OPTIONAL*
+	// defaults to the type of the target
+	Name string `yaml:"name"`
+
+	// Type of the publish target *REQUIRED*
+	//
+	// "aptly":
+	// publish to a local repository of an aptly server using its REST API
+	// needs url, repo and distribution
+	//
+	// "packagecloud":
+	// publish to a packagecloud.io repository
+	// needs repo (user/repo), distribution (e.g. ubuntu/focal) and the API token as password
+	//
+	// "s3":
+	// copy packages to an S3 bucket using the aws cli, e.g. to mirror them
+	// needs url (s3://bucket/path), packages are stored below <url>/<distribution>/
+	Type string `yaml:"type"`
+
+	// URL of the server or bucket
+	URL string `yaml:"url"`
+
+	// credentials for basic authentication *OPTIONAL*
+	// use environment variables to keep them out of packages.yml
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+
+	// Repo is the repository packages are added to
+	Repo string `yaml:"repo"`
+
+	// Distribution the repository is published as
+	Distribution string `yaml:"distribution"`
+
+	// Prefix the repository is published under *OPTIONAL*
+	Prefix string `yaml:"prefix"`
+
+	// ChunkSize uploads packages larger than this many MiB in parts, only for s3 *OPTIONAL*
+	// unfinished uploads are resumed by the next run and every part is verified
+	ChunkSize int `yaml:"chunk_size"`
+
+	// OIDC exchanges the OIDC token of the workflow for short-lived credentials, only for s3 *OPTIONAL*
+	// the aws cli uses credentials from the environment otherwise
+	OIDC *OIDC `yaml:"oidc"`
+
+	// Quarantine publishes packages to a separate suite first *OPTIONAL*
+	// packages are only promoted to Repo/Distribution once verified and approved
+	Quarantine *Quarantine `yaml:"quarantine"`
+}
+
+// Quarantine configures the staging suite and the gate packages have to pass before promotion
+type Quarantine struct {
+	// Repo and Distribution of the quarantine suite *REQUIRED*
+	Repo         string `yaml:"repo"`
+	Distribution string `yaml:"distribution"`
+
+	// Verify lists shell commands that have to succeed before packages are promoted *OPTIONAL*
+	// the published packages are available to them in the environment variable PACKAGES
+	Verify []string `yaml:"verify"`
+
+	// ApprovalEnv names an environment variable that has to be "true" for packages to be promoted *OPTIONAL*
+	// without approval packages stay in quarantine until the action is run with command "promote"
+	ApprovalEnv string `yaml:"approval_env"`
+}
+
+// Suite identifies a repository and distribution of a publish target
+type Suite struct {
+	Repo         string
+	Distribution string
+}
+
+// Publisher is implemented by all types of publish targets
+type Publisher interface {
+	// Publish adds a package file to a suite
+	Publish(artifact string, s Suite) error
+
+	// Promote moves a published package from one suite to another
+	Promote(p *Package, from, to Suite) error
+
+	// Describe explains where a package file would be published to and what happens to existing packages
+	Describe(artifact string, s Suite) string
+
+	// Check verifies the credentials and that the suite exists without transferring packages
+	Check(s Suite) error
+}
+
+// method publisher creates the Publisher for the type of the target
+func (t *PublishTarget) publisher() Publisher {
+	switch t.Type {
+	case "aptly":
+		return &aptlyPublisher{target: t}
+	case "packagecloud":
+		return &packagecloudPublisher{target: t}
+	case "s3":
+		return &s3Publisher{target: t}
+	}
+	return nil
+}
+
+// method name returns the name of the target used in logs and the report
+func (t *PublishTarget) name() string {
+	if t.Name == "" {
+		return t.Type
+	}
+	return t.Name
+}
+
+// method supports decides whether the target can publish packages of a target mode
+func (t *PublishTarget) supports(mode string) bool {
+	switch t.Type {
+	case "aptly":
+		return mode == "deb"
+	case "packagecloud":
+		return mode == "deb" || mode == "rpm"
+	}
+	// file trees of mode "dir" are for inspection only
+	return mode != "dir"
+}
+
+// method suite returns the public suite of the target
+func (t *PublishTarget) suite() Suite {
+	return Suite{Repo: t.Repo, Distribution: t.Distribution}
+}
+
+// method quarantineSuite returns the quarantine suite of the target
+func (t *PublishTarget) quarantineSuite() Suite {
+	return Suite{Repo: t.Quarantine.Repo, Distribution: t.Quarantine.Distribution}
+}
+
+// method check validates the publish configuration
+func (t *PublishTarget) check(i int) error {
+	field := fmt.Sprintf("publish[%d]", i)
+
+	validTypes := []string{"aptly", "packagecloud", "s3"}
+	if !contains(validTypes, t.Type) {
+		return ConfigError{
+			field:   field + ".type",
+			message: fmt.Sprintf(tr("publish type is required and may contain %s"), strings.Join(validTypes, "|")),
+		}
+	}
+
+	switch t.Type {
+	case "aptly":
+		if t.URL == "" || t.Repo == "" || t.Distribution == "" {
+			return ConfigError{
+				field:   field,
+				message: "url, repo and distribution are required for aptly",
+			}
+		}
+	case "packagecloud":
+		if strings.Count(t.Repo, "/") != 1 || strings.Count(t.Distribution, "/") != 1 || t.Password == "" {
+			return ConfigError{
+				field:   field,
+				message: "repo (user/repo), distribution (os/version) and password (API token) are required for packagecloud",
+			}
+		}
+	case "s3":
+		if !strings.HasPrefix(t.URL, "s3://") {
+			return ConfigError{
+				field:   field + ".url",
+				message: "an url of the form s3://bucket/path is required for s3",
+			}
+		}
+		if t.ChunkSize != 0 && (t.ChunkSize < 5 || t.ChunkSize > 5120) {
+			return ConfigError{
+				field:   field + ".chunk_size",
+				message: "S3 accepts parts between 5 and 5120 MiB",
+			}
+		}
+	}
+	if t.Type != "s3" && t.ChunkSize != 0 {
+		return ConfigError{
+			field:   field + ".chunk_size",
+			message: "chunk_size is only available for s3",
+		}
+	}
+	if t.OIDC != nil {
+		if t.Type != "s3" {
+			return ConfigError{
+				field:   field + ".oidc",
+				message: "oidc is only available for s3, aptly and packagecloud authenticate with username and password",
+			}
+		}
+		if err := t.OIDC.check(field + ".oidc"); err != nil {
+			return err
+		}
+	}
+
+	if t.Quarantine != nil && (t.Quarantine.Distribution == "" || (t.Type != "s3" && t.Quarantine.Repo == "")) {
+		return ConfigError{
+			field:   field + ".quarantine",
+			message: "repo and distribution of the quarantine suite are required",
+		}
+	}
+	return nil
+}
+
+// function verify runs the verification commands of the quarantine
+func verify(commands []string, artifacts []string) error {
+	for _, v := range commands {
+		fmt.Printf("verifying: %s\n", v)
+		cmd := exec.Command("sh", "-c", v)
+		cmd.Env = append(os.Environ(), "PACKAGES="+strings.Join(artifacts, " "))
+		output, err := cmd.CombinedOutput()
+		fmt.Printf("%s", output)
+		if err != nil {
+			return fmt.Errorf("verification %q failed: %s", v, err)
+		}
+	}
+	return nil
+}
+
+// method checkTarget verifies the credentials of a target for its public and quarantine suite
+func (t *PublishTarget) checkTarget() error {
+	publisher := t.publisher()
+	suites := []Suite{t.suite()}
+	if t.Quarantine != nil {
+		suites = append(suites, t.quarantineSuite())
+	}
+	for _, s := range suites {
+		if err := publisher.Check(s); err != nil {
+			return err
+		}
+		fmt.Printf("verified access to %s %s\n", t.name(), strings.Trim(s.Repo+"/"+s.Distribution, "/"))
+	}
+	return nil
+}
+
+// method publish publishes all successfully built packages to every target
+//
+// a failing target does not stop publishing to the other targets, the result
+// of every target is recorded in the report of each package.
+// with dryRun nothing is uploaded, the destinations are printed and recorded instead.
+// verifyCredentials checks the access to every target before anything is published
+func (c *FPMConfig) publish(dryRun, verifyCredentials bool) error {
+	failed := []string{}
+	for i := range c.Publish {
+		t := &c.Publish[i]
+		if verifyCredentials {
+			if err := t.withCredentials(t.checkTarget); err != nil {
+				fmt.Printf("verifying the access to %s failed: %s\n", t.name(), err)
+				failed = append(failed, t.name())
+				continue
+			}
+		}
+		var err error
+		if dryRun {
+			// dry runs do not access the target, so no credentials are needed
+			err = c.publishTo(t, true)
+		} else {
+			err = t.withCredentials(func() error { return c.publishTo(t, false) })
+		}
+		if err != nil {
+			fmt.Printf("publishing to %s failed: %s\n", t.name(), err)
+			failed = append(failed, t.name())
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("publishing to %s failed", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// method publishTo publishes all successfully built packages to a single target
+//
+// with a quarantine, packages are published to the quarantine suite and only promoted
+// after all verification commands succeeded and the approval (if configured) was given
+func (c *FPMConfig) publishTo(t *PublishTarget, dryRun bool) error {
+	publisher := t.publisher()
+
+	suite := t.suite()
+	if t.Quarantine != nil {
+		suite = t.quarantineSuite()
+	}
+
+	artifacts := []string{}
+	var failed error
+	for i := range c.report.Packages {
+		r := &c.report.Packages[i]
+		if r.Status != "success" || r.Artifact == "" || !t.supports(r.Mode) {
+			continue
+		}
+
+		result := PublishResult{
+			Target: t.name(),
+			Suite:  strings.Trim(suite.Repo+"/"+suite.Distribution, "/"),
+			Status: "success",
+		}
+		if dryRun {
+			result.Status = "dry-run"
+			result.Destination = publisher.Describe(r.Artifact, suite)
+			fmt.Printf("would publish %s to %s: %s\n", r.Artifact, t.name(), result.Destination)
+			r.Published = append(r.Published, result)
+			continue
+		}
+
+		fmt.Printf("publishing %s to %s %s/%s...\n", r.Artifact, t.name(), suite.Repo, suite.Distribution)
+		if err := publisher.Publish(r.Artifact, suite); err != nil {
+			result.Status = "failed"
+			result.Error = err.Error()
+			failed = err
+		} else {
+			artifacts = append(artifacts, r.Artifact)
+		}
+		r.Published = append(r.Published, result)
+	}
+
+	if failed != nil || t.Quarantine == nil {
+		return failed
+	}
+	if dryRun {
+		fmt.Printf("would promote the packages from quarantine to %s %s/%s after verification\n", t.name(), t.Repo, t.Distribution)
+		return nil
+	}
+
+	if err := verify(t.Quarantine.Verify, artifacts); err != nil {
+		return err
+	}
+
+	if t.Quarantine.ApprovalEnv != "" && os.Getenv(t.Quarantine.ApprovalEnv) != "true" {
+		fmt.Printf("packages stay in quarantine of %s until %s is set to true or command promote is run\n", t.name(), t.Quarantine.ApprovalEnv)
+		return nil
+	}
+
+	return c.promoteTo(t)
+}
+
+// method promote moves all configured packages from the quarantine suites to the public suites
+func (c *FPMConfig) promote() error {
+	promoted := false
+	for i := range c.Publish {
+		t := &c.Publish[i]
+		if t.Quarantine == nil {
+			continue
+		}
+		if err := t.withCredentials(func() error { return c.promoteTo(t) }); err != nil {
+			return fmt.Errorf("%s: %s", t.name(), err)
+		}
+		promoted = true
+	}
+	if !promoted {
+		return fmt.Errorf("promotion requires a publish target with a quarantine")
+	}
+	return nil
+}
+
+// method promoteTo moves all configured packages from the quarantine suite of a target to its public suite
+func (c *FPMConfig) promoteTo(t *PublishTarget) error {
+	publisher := t.publisher()
+	for i := range c.Packages {
+		p := &c.Packages[i]
+		if !t.supports(p.Target.Mode) {
+			continue
+		}
+		fmt.Printf("promoting %s %s to %s %s/%s...\n", p.Name, p.Target.Version, t.name(), t.Repo, t.Distribution)
+		if err := publisher.Promote(p, t.quarantineSuite(), t.suite()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
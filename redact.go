@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// environment variables holding credentials, their values are always masked
+var secretEnv = []string{
+	"GITHUB_TOKEN",
+	"ACTIONS_RUNTIME_TOKEN",
+	"ACTIONS_ID_TOKEN_REQUEST_TOKEN",
+	"VAULT_TOKEN",
+	"AWS_SECRET_ACCESS_KEY",
+	"AWS_SESSION_TOKEN",
+}
+
+// shorter values are not masked, they would garble the whole output
+const minSecretLength = 4
+
+// redactor masks secrets in everything the action prints or writes
+// stdout and stderr are replaced by pipes, so the output of fpm and all other commands is masked as well
+type redactor struct {
+	mutex   sync.Mutex
+	secrets []string
+
+	// console is the original stdout, masks are announced to GitHub there
+	console *os.File
+
+	writers []*os.File
+	wait    sync.WaitGroup
+
+	// tap receives the masked output instead of the original files, e.g. the terminal view
+	tap io.Writer
+}
+
+// redaction is the redactor shared by all output of the action
+var redaction = &redactor{}
+
+// method add registers a secret value, multi line values are masked line by line as well
+func (r *redactor) add(value string) {
+	values := []string{value}
+	if strings.Contains(value, "\n") {
+		values = append(values, strings.Split(value, "\n")...)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if len(v) < minSecretLength || contains(r.secrets, v) {
+			continue
+		}
+		r.secrets = append(r.secrets, v)
+
+		// let GitHub mask the value in the logs of following steps too
+		if r.console != nil && os.Getenv("GITHUB_ACTIONS") == "true" {
+			fmt.Fprintf(r.console, "::add-mask::%s\n", v)
+		}
+	}
+
+	// replace longer secrets first so no parts of them remain
+	sort.SliceStable(r.secrets, func(i, j int) bool {
+		return len(r.secrets[i]) > len(r.secrets[j])
+	})
+}
+
+// method addEnv registers the values of environment variables as secrets
+func (r *redactor) addEnv(names ...string) {
+	for _, n := range names {
+		if v := os.Getenv(n); v != "" {
+			r.add(v)
+		}
+	}
+}
+
+// method redact replaces all registered secrets in s
+func (r *redactor) redact(s string) string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, "***")
+	}
+	return s
+}
+
+// method stream replaces the file *f by a pipe and copies the masked lines to the original file
+func (r *redactor) stream(f **os.File) error {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	original := *f
+	*f = writer
+	r.writers = append(r.writers, writer)
+
+	r.wait.Add(1)
+	go func() {
+		defer r.wait.Done()
+		lines := bufio.NewReader(reader)
+		for {
+			line, err := lines.ReadString('\n')
+			io.WriteString(r.output(original), r.redact(line))
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// method setTap redirects the masked output to w, nil restores the original files
+func (r *redactor) setTap(w io.Writer) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.tap = w
+}
+
+// method output returns where masked output of the original file is written to
+func (r *redactor) output(original io.Writer) io.Writer {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.tap != nil {
+		return r.tap
+	}
+	return original
+}
+
+// method start masks stdout and stderr
+func (r *redactor) start() error {
+	r.console = os.Stdout
+	if err := r.stream(&os.Stdout); err != nil {
+		return err
+	}
+	return r.stream(&os.Stderr)
+}
+
+// method close flushes the masked output, it has to be called before exiting
+func (r *redactor) close() {
+	for _, w := range r.writers {
+		w.Close()
+	}
+	r.writers = nil
+	r.wait.Wait()
+}
+
+// method collectSecrets registers all credentials of the configuration
+// values inserted from the environment are masked by their variable names listed in redact
+func (c *FPMConfig) collectSecrets() {
+	redaction.addEnv(c.Redact...)
+	for _, t := range c.Publish {
+		redaction.add(t.Password)
+	}
+	for _, p := range c.Packages {
+		redaction.add(p.Target.APKKey)
+	}
+}
+
+// function exit flushes the masked output and exits with the given code
+// the terminal view prints its summary once all output is flushed
+func exit(code int) {
+	redaction.close()
+	ui.close()
+	os.Exit(code)
+}
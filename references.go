@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// references to fields of other packages look like ${packages.<name>.<field>}
+// the field is named like in packages.yml, the name may contain dots
+var referencePattern = regexp.MustCompile(`\$\{packages\.([^}]+)\.([a-z_]+)\}`)
+
+// function isReference decides whether a ${variable} of packages.yml references another package
+// references are kept when reading the file and resolved once all packages are known
+func isReference(name string) bool {
+	return strings.HasPrefix(name, "packages.")
+}
+
+// method field returns the value of a string field of the package by its name in packages.yml
+func (p *Package) field(name string) (string, bool) {
+	if name == "name" {
+		return p.Name, true
+	}
+	target := reflect.ValueOf(p.Target)
+	for i := 0; i < target.NumField(); i++ {
+		tag := target.Type().Field(i).Tag.Get("yaml")
+		if tag == name && target.Field(i).Kind() == reflect.String {
+			return target.Field(i).String(), true
+		}
+	}
+	return "", false
+}
+
+// function stringFields returns pointers to all strings of a struct, including strings of slices
+// fields that are not read from packages.yml are skipped
+func stringFields(v reflect.Value) []*string {
+	fields := []*string{}
+	for i := 0; i < v.NumField(); i++ {
+		if v.Type().Field(i).Tag.Get("yaml") == "-" {
+			continue
+		}
+		f := v.Field(i)
+		switch f.Kind() {
+		case reflect.String:
+			fields = append(fields, f.Addr().Interface().(*string))
+		case reflect.Slice:
+			if f.Type().Elem().Kind() == reflect.String {
+				for j := 0; j < f.Len(); j++ {
+					fields = append(fields, f.Index(j).Addr().Interface().(*string))
+				}
+			}
+		}
+	}
+	return fields
+}
+
+// method lookup resolves a single reference, ok is false if the referenced value contains references itself
+func (c *FPMConfig) lookup(name, field string) (value string, ok bool, err error) {
+	for i := range c.Packages {
+		if c.Packages[i].Name != name {
+			continue
+		}
+		value, found := c.Packages[i].field(field)
+		if !found {
+			return "", false, fmt.Errorf("package %s has no field %s", name, field)
+		}
+		return value, !referencePattern.MatchString(value), nil
+	}
+	return "", false, fmt.Errorf("there is no package %s", name)
+}
+
+// method resolveReferences replaces ${packages.<name>.<field>} in all fields of all packages
+// it runs after the versions are set by dispatch inputs, references may be chained but not circular
+func (c *FPMConfig) resolveReferences() error {
+	for pass := 0; pass <= len(c.Packages); pass++ {
+		unresolved := false
+		for i := range c.Packages {
+			p := &c.Packages[i]
+			fields := append([]*string{&p.Name}, stringFields(reflect.ValueOf(&p.Target).Elem())...)
+			fields = append(fields, stringFields(reflect.ValueOf(&p.Source).Elem())...)
+			for j := range p.Paths {
+				fields = append(fields, &p.Paths[j])
+			}
+
+			for _, f := range fields {
+				var lookupErr error
+				*f = referencePattern.ReplaceAllStringFunc(*f, func(reference string) string {
+					m := referencePattern.FindStringSubmatch(reference)
+					value, ok, err := c.lookup(m[1], m[2])
+					if err != nil {
+						lookupErr = err
+					}
+					if !ok {
+						unresolved = true
+						return reference
+					}
+					return value
+				})
+				if lookupErr != nil {
+					return ConfigError{
+						packageEntry: p.Name,
+						field:        "${packages.*}",
+						message:      lookupErr.Error(),
+					}
+				}
+			}
+		}
+		if !unresolved {
+			return nil
+		}
+	}
+	return ConfigError{
+		field:   "${packages.*}",
+		message: "package references are circular",
+	}
+}
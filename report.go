@@ -0,0 +1,151 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+)
+
+// Report collects the results of all packages built in a single run
+type Report struct {
+	// RunID identifies the run, it allows jobs aggregating matrix legs to correlate results
+	RunID string `json:"run_id"`
+
+	// Provenance is the commit the packages were built from, if the workspace is a git repository
+	Provenance *Provenance `json:"provenance,omitempty"`
+
+	Packages []PackageReport `json:"packages"`
+
+	// Environment describes the runner and the tools used to build the packages
+	Environment *Environment `json:"environment,omitempty"`
+
+	// Skipped lists the packages not affected by the changes of the pull request
+	Skipped []string `json:"skipped,omitempty"`
+}
+
+// PackageReport contains the result of building a single package
+type PackageReport struct {
+	Name    string `json:"name"`
+	Mode    string `json:"mode"`
+	Version string `json:"version"`
+
+	// Status is either "success" or "failed"
+	Status string `json:"status"`
+
+	// Artifact is the path of the package file created by fpm
+	Artifact string `json:"artifact,omitempty"`
+
+	// Expires is the time the package is outdated, if an expiry is configured
+	Expires string `json:"expires,omitempty"`
+
+	// Signatures are the paths of the detached signatures of the artifact
+	Signatures []string `json:"signatures,omitempty"`
+
+	// Licenses found in the package contents by the license audit
+	Licenses []LicenseFinding `json:"licenses,omitempty"`
+
+	// Vulnerabilities counts the findings of the vulnerability scan by severity
+	Vulnerabilities map[string]int `json:"vulnerabilities,omitempty"`
+
+	// Published lists the result of publishing the package to each publish target
+	Published []PublishResult `json:"published,omitempty"`
+
+	// Timings are the seconds spent in every stage of building, they are only recorded by benchmarks
+	Timings map[string]float64 `json:"timings,omitempty"`
+
+	// Warnings that do not fail the build but should be looked at
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// PublishResult is the result of publishing a package to a single publish target
+type PublishResult struct {
+	Target string `json:"target"`
+	Suite  string `json:"suite"`
+
+	// Status is either "success", "failed" or "dry-run"
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+
+	// Destination describes where the package would be published to, it is only set for dry runs
+	Destination string `json:"destination,omitempty"`
+}
+
+// fpm logs the created package as :path=>"example_1.0_amd64.deb"
+var artifactPattern = regexp.MustCompile(`:path=>"([^"]+)"`)
+
+// function artifactPath extracts the path of the created package from the fpm output
+func artifactPath(output []byte) string {
+	m := artifactPattern.FindSubmatch(output)
+	if m == nil {
+		return ""
+	}
+	return string(m[1])
+}
+
+// method writeReport stores the report as json if a report path is configured
+func (c *FPMConfig) writeReport() error {
+	if c.Report == "" {
+		return nil
+	}
+	contents, err := json.MarshalIndent(c.report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.Report, []byte(redaction.redact(string(contents))), 0644)
+}
+
+// method fail records a failed package in the report and exits with a non-zero exit code
+func (c *FPMConfig) fail(r PackageReport) {
+	r.Status = "failed"
+	c.report.Packages = append(c.report.Packages, r)
+	ui.built(r)
+	c.writeReport()
+	c.finish(2)
+}
+
+// function newRunID creates a random version 4 UUID
+func newRunID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// method finish prints a machine readable summary of the run and exits with the given code
+//
+// the summary is a single line like
+// ::notice::action-package result=success built=4 failed=0 exit=0 run_id=<uuid>
+// result, run_id, built and failed are set as step outputs as well
+func (c *FPMConfig) finish(code int) {
+	result := "success"
+	if code != 0 {
+		result = "failure"
+	}
+	built, failed := 0, 0
+	for _, p := range c.report.Packages {
+		if p.Status == "failed" {
+			failed++
+		} else {
+			built++
+		}
+	}
+
+	fmt.Printf("::notice::action-package result=%s built=%d failed=%d exit=%d run_id=%s\n",
+		result, built, failed, code, c.report.RunID)
+
+	if path := os.Getenv("GITHUB_OUTPUT"); path != "" {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err == nil {
+			fmt.Fprintf(f, "result=%s\nbuilt=%d\nfailed=%d\nrun_id=%s\n", result, built, failed, c.report.RunID)
+			f.Close()
+		}
+	}
+
+	exit(code)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// sizes of memory limits like 512M or 4G
+var memoryPattern = regexp.MustCompile(`^([0-9]+)([KMG])$`)
+
+// classes of ionice by name, realtime is left out as it would starve other processes
+var ioClasses = map[string]string{
+	"best-effort": "2",
+	"idle":        "3",
+}
+
+// Resources limits the processes building a package, e.g. on self-hosted runners shared with other workloads
+// they apply to fpm and the compiler of source mode go
+type Resources struct {
+	// Nice is the CPU niceness from -20 (favored) to 19 (least favored) *OPTIONAL*
+	Nice *int `yaml:"nice"`
+
+	// IOClass is the IO scheduling class: best-effort|idle *OPTIONAL*
+	// processes of class idle only access disks no other process is waiting for
+	IOClass string `yaml:"io_class"`
+
+	// IOPriority within class best-effort from 0 (highest) to 7 (lowest) *OPTIONAL*
+	IOPriority *int `yaml:"io_priority"`
+
+	// Memory limits the address space of every process e.g. "4G", suffixes are K, M and G *OPTIONAL*
+	Memory string `yaml:"memory"`
+}
+
+// method check validates the limits
+func (r *Resources) check(p *Package) error {
+	var field, message string
+	switch {
+	case r.Nice != nil && (*r.Nice < -20 || *r.Nice > 19):
+		field, message = "resources.nice", "niceness has to be between -20 and 19"
+	case r.IOClass != "" && ioClasses[r.IOClass] == "":
+		field, message = "resources.io_class", "the io class may contain best-effort|idle"
+	case r.IOPriority != nil && r.IOClass != "best-effort":
+		field, message = "resources.io_priority", "io_priority requires io_class best-effort"
+	case r.IOPriority != nil && (*r.IOPriority < 0 || *r.IOPriority > 7):
+		field, message = "resources.io_priority", "io priorities are between 0 and 7"
+	case r.Memory != "" && !memoryPattern.MatchString(r.Memory):
+		field, message = "resources.memory", "memory has to be a number with the suffix K, M or G e.g. 4G"
+	}
+	if message == "" {
+		return nil
+	}
+	return ConfigError{
+		packageEntry: p.Name,
+		field:        field,
+		message:      message,
+	}
+}
+
+// function memoryBytes converts a memory limit like 4G to bytes
+func memoryBytes(memory string) int64 {
+	m := memoryPattern.FindStringSubmatch(memory)
+	n, _ := strconv.ParseInt(m[1], 10, 64)
+	return n << (10 * int64(strings.Index("KMG", m[2])+1))
+}
+
+// method command returns the command running name with args within the limits
+// nice, ionice and prlimit wrap each other, resources without limits run the command directly
+func (r *Resources) command(name string, args ...string) (string, []string) {
+	if r == nil {
+		return name, args
+	}
+	wrappers := []string{}
+	if r.Nice != nil {
+		wrappers = append(wrappers, "nice", "-n", strconv.Itoa(*r.Nice))
+	}
+	if r.IOClass != "" {
+		wrappers = append(wrappers, "ionice", "-c", ioClasses[r.IOClass])
+		if r.IOPriority != nil {
+			wrappers = append(wrappers, "-n", strconv.Itoa(*r.IOPriority))
+		}
+	}
+	if r.Memory != "" {
+		wrappers = append(wrappers, "prlimit", fmt.Sprintf("--as=%d", memoryBytes(r.Memory)), "--")
+	}
+	if len(wrappers) == 0 {
+		return name, args
+	}
+	return wrappers[0], append(append(wrappers[1:], name), args...)
+}
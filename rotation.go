@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Keyring configures a package that installs the public signing keys on the hosts
+// during a key rotation it ships the old and the new key, so apt keeps trusting the repository
+type Keyring struct {
+	// Name of the keyring package *REQUIRED*
+	Name string `yaml:"name"`
+
+	// Version of the keyring package *REQUIRED*
+	// increase it whenever the list of keys changes
+	Version string `yaml:"version"`
+
+	// PublicKeys are files containing the public keys, armored or binary *REQUIRED*
+	PublicKeys []string `yaml:"public_keys"`
+
+	// Path the keyring is installed to, defaults to /usr/share/keyrings/<name>.gpg
+	Path string `yaml:"path"`
+}
+
+// method check validates the keyring configuration
+func (k *Keyring) check() error {
+	if k.Name == "" || k.Version == "" || len(k.PublicKeys) == 0 {
+		return ConfigError{
+			field:   "signing.keyring",
+			message: "name, version and public_keys of the keyring package are required",
+		}
+	}
+	return nil
+}
+
+// function dearmor converts an ascii armored OpenPGP block to its binary form
+// binary input is returned unchanged
+func dearmor(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("-----BEGIN PGP")) {
+		return data, nil
+	}
+
+	body := &strings.Builder{}
+	headers := true
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "-----BEGIN"):
+			continue
+		case strings.HasPrefix(line, "-----END"), strings.HasPrefix(line, "="):
+			return base64.StdEncoding.DecodeString(body.String())
+		case headers:
+			// armor headers end with an empty line
+			headers = line != ""
+		default:
+			body.WriteString(line)
+		}
+	}
+	return nil, fmt.Errorf("armored data is not terminated")
+}
+
+// method keyringPackage creates the package entry installing the configured public keys
+func (k *Keyring) keyringPackage() (Package, error) {
+	p := Package{Name: k.Name}
+
+	keyring := &bytes.Buffer{}
+	for _, path := range k.PublicKeys {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return p, err
+		}
+		key, err := dearmor(data)
+		if err != nil {
+			return p, fmt.Errorf("%s: %s", path, err)
+		}
+		keyring.Write(key)
+	}
+
+	dir, err := ioutil.TempDir("", "keyring-")
+	if err != nil {
+		return p, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "keyring.gpg"), keyring.Bytes(), 0644); err != nil {
+		return p, err
+	}
+
+	target := k.Path
+	if target == "" {
+		target = fmt.Sprintf("/usr/share/keyrings/%s.gpg", k.Name)
+	}
+
+	p.Source.Mode = "dir"
+	p.Source.Chdir = dir
+	p.Paths = []string{"keyring.gpg=" + target}
+	p.Target.Modes = Modes{"deb"}
+	p.Target.Mode = "deb"
+	p.Target.Version = k.Version
+	p.Target.Description = "keyring containing the package signing keys"
+	return p, nil
+}
+
+// method addKeyring appends the keyring package to the packages to build
+func (c *FPMConfig) addKeyring() error {
+	if c.Signing == nil || c.Signing.Keyring == nil {
+		return nil
+	}
+	p, err := c.Signing.Keyring.keyringPackage()
+	if err != nil {
+		return err
+	}
+	c.Packages = append(c.Packages, p)
+	return nil
+}
+
+// method signReleaseFiles signs the Release files of apt repositories with all keys
+//
+// Release.gpg contains one detached signature per key, for signer gpg an InRelease file
+// signed by all keys is created as well. apt accepts the repository as long as it trusts one of the keys
+func (s *Signing) signReleaseFiles() error {
+	for _, release := range s.ReleaseFiles {
+		data, err := ioutil.ReadFile(release)
+		if err != nil {
+			return err
+		}
+
+		signatures := &bytes.Buffer{}
+		for _, signer := range s.signers() {
+			signature, err := signer.Sign(bytes.NewReader(data))
+			if err != nil {
+				return err
+			}
+			signatures.Write(signature)
+		}
+		dir := filepath.Dir(release)
+		if err := ioutil.WriteFile(filepath.Join(dir, "Release.gpg"), signatures.Bytes(), 0644); err != nil {
+			return err
+		}
+
+		if s.Signer == "gpg" {
+			args := []string{"--batch", "--yes", "--clearsign", "--output", filepath.Join(dir, "InRelease")}
+			for _, k := range append([]string{s.Key}, s.Keys...) {
+				args = append(args, "--local-user", k)
+			}
+			if output, err := exec.Command("gpg", append(args, release)...).CombinedOutput(); err != nil {
+				return fmt.Errorf("could not create InRelease: %s\n%s", err, output)
+			}
+		}
+		fmt.Printf("signed %s with %d keys\n", release, 1+len(s.Keys))
+	}
+	return nil
+}
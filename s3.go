@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// s3Publisher copies packages to an S3 bucket using the aws cli
+// credentials are taken from the environment as usual for the aws cli
+type s3Publisher struct {
+	target *PublishTarget
+}
+
+// method location returns the S3 url packages of a suite are stored at
+func (s3 *s3Publisher) location(s Suite) string {
+	return strings.TrimSuffix(s3.target.URL, "/") + "/" + strings.Trim(s.Distribution, "/") + "/"
+}
+
+// function aws runs the aws cli and includes its output in errors
+func aws(args ...string) error {
+	output, err := exec.Command("aws", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("aws %s failed: %s\n%s", strings.Join(args, " "), err, output)
+	}
+	return nil
+}
+
+// method Publish uploads a package below the location of the suite
+// packages larger than the chunk size are uploaded in parts
+func (s3 *s3Publisher) Publish(artifact string, s Suite) error {
+	destination := s3.location(s) + filepath.Base(artifact)
+	if s3.target.ChunkSize > 0 {
+		info, err := os.Stat(artifact)
+		if err != nil {
+			return err
+		}
+		if info.Size() > int64(s3.target.ChunkSize)<<20 {
+			return multipartUpload(artifact, destination, s3.target.ChunkSize)
+		}
+	}
+	return aws("s3", "cp", artifact, destination)
+}
+
+// method Describe implements Publisher
+func (s3 *s3Publisher) Describe(artifact string, s Suite) string {
+	return fmt.Sprintf("copy to %s%s, an existing object is overwritten", s3.location(s), filepath.Base(artifact))
+}
+
+// method Check implements Publisher
+// accessing the bucket verifies the credentials, write access can not be checked without writing
+func (s3 *s3Publisher) Check(s Suite) error {
+	bucket := strings.SplitN(strings.TrimPrefix(s3.target.URL, "s3://"), "/", 2)[0]
+	return aws("s3api", "head-bucket", "--bucket", bucket)
+}
+
+// method Promote moves all files of a package version from one suite to another
+func (s3 *s3Publisher) Promote(p *Package, from, to Suite) error {
+	return aws("s3", "mv", s3.location(from), s3.location(to), "--recursive",
+		"--exclude", "*", "--include", fmt.Sprintf("%s_%s*", p.Name, p.Target.Version))
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Scan configures the vulnerability scan of built packages
+type Scan struct {
+	// Scanner is the tool used to scan the package contents *REQUIRED*
+	//
+	// "grype": use anchore grype
+	// "trivy": use aquasecurity trivy
+	Scanner string `yaml:"scanner"`
+
+	// FailOn is the lowest severity that fails the build *OPTIONAL*
+	FailOn string `yaml:"fail_on"`
+
+	// WarnOn is the lowest severity that is reported as a warning *OPTIONAL*
+	WarnOn string `yaml:"warn_on"`
+}
+
+// severities in ascending order
+var severities = []string{"negligible", "low", "medium", "high", "critical"}
+
+// function severityRank returns the position of a severity in severities or -1 if it is unknown
+func severityRank(s string) int {
+	for i, v := range severities {
+		if strings.EqualFold(v, s) {
+			return i
+		}
+	}
+	return -1
+}
+
+// function atLeast decides whether severity s reaches the threshold
+// an empty threshold is never reached
+func atLeast(s, threshold string) bool {
+	if threshold == "" {
+		return false
+	}
+	return severityRank(s) >= severityRank(threshold)
+}
+
+// Vulnerability is a single finding of the scanner
+type Vulnerability struct {
+	ID       string
+	Severity string
+}
+
+// function grype scans a directory using grype
+func grype(dir string) ([]Vulnerability, error) {
+	output, err := exec.Command("grype", "dir:"+dir, "-o", "json", "-q").Output()
+	if err != nil {
+		return nil, err
+	}
+	result := struct {
+		Matches []struct {
+			Vulnerability struct {
+				ID       string `json:"id"`
+				Severity string `json:"severity"`
+			} `json:"vulnerability"`
+		} `json:"matches"`
+	}{}
+	if err := json.Unmarshal(output, &result); err != nil {
+		return nil, err
+	}
+	vulnerabilities := []Vulnerability{}
+	for _, m := range result.Matches {
+		vulnerabilities = append(vulnerabilities, Vulnerability{m.Vulnerability.ID, m.Vulnerability.Severity})
+	}
+	return vulnerabilities, nil
+}
+
+// function trivy scans a directory using trivy
+func trivy(dir string) ([]Vulnerability, error) {
+	output, err := exec.Command("trivy", "fs", "--format", "json", "--quiet", dir).Output()
+	if err != nil {
+		return nil, err
+	}
+	result := struct {
+		Results []struct {
+			Vulnerabilities []struct {
+				VulnerabilityID string
+				Severity        string
+			}
+		}
+	}{}
+	if err := json.Unmarshal(output, &result); err != nil {
+		return nil, err
+	}
+	vulnerabilities := []Vulnerability{}
+	for _, r := range result.Results {
+		for _, v := range r.Vulnerabilities {
+			vulnerabilities = append(vulnerabilities, Vulnerability{v.VulnerabilityID, v.Severity})
+		}
+	}
+	return vulnerabilities, nil
+}
+
+// function extractPackage unpacks the files contained in a package file into dir
+func extractPackage(artifact, dir string) error {
+	var cmd *exec.Cmd
+	switch filepath.Ext(artifact) {
+	case ".tar", ".zst", ".xz", ".gz", ".bz2":
+		// tar archives and pacman packages are plain tar archives, pacman keeps metadata files in the root
+		cmd = exec.Command("tar", "-xf", artifact, "-C", dir, "--exclude", ".*")
+	case ".txz":
+		// freebsd packages keep their metadata in +MANIFEST files in the root
+		cmd = exec.Command("tar", "-xf", artifact, "-C", dir, "--exclude", "+*")
+	case ".zip":
+		cmd = exec.Command("unzip", "-q", artifact, "-d", dir)
+	case ".deb":
+		cmd = exec.Command("dpkg-deb", "-x", artifact, dir)
+	case ".apk":
+		cmd = exec.Command("tar", "-xzf", artifact, "-C", dir, "--ignore-zeros", "--exclude", ".*")
+	case ".pkg":
+		// pkgutil creates the directory itself
+		cmd = exec.Command("pkgutil", "--expand-full", artifact, filepath.Join(dir, "pkg"))
+	case ".rpm":
+		cmd = exec.Command("sh", "-c", `rpm2cpio "$1" | (cd "$2" && cpio -idm --quiet)`, "extract", artifact, dir)
+	default:
+		return fmt.Errorf("do not know how to extract %s", artifact)
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("could not extract %s: %s\n%s", artifact, err, output)
+	}
+	return nil
+}
+
+// method run extracts a built package and scans its contents
+// findings are recorded in the report, an error is returned if a finding reaches FailOn
+func (s *Scan) run(artifact string, r *PackageReport) error {
+	if artifact == "" {
+		return fmt.Errorf("the created package could not be determined from the fpm output")
+	}
+
+	dir, err := ioutil.TempDir("", "scan-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	// file trees of target mode "dir" are scanned in place
+	if info, err := os.Stat(artifact); err == nil && info.IsDir() {
+		dir = artifact
+	} else if err := extractPackage(artifact, dir); err != nil {
+		return err
+	}
+
+	var vulnerabilities []Vulnerability
+	switch s.Scanner {
+	case "grype":
+		vulnerabilities, err = grype(dir)
+	case "trivy":
+		vulnerabilities, err = trivy(dir)
+	}
+	if err != nil {
+		return fmt.Errorf("%s failed: %s", s.Scanner, err)
+	}
+
+	r.Vulnerabilities = map[string]int{}
+	failed := []string{}
+	for _, v := range vulnerabilities {
+		severity := strings.ToLower(v.Severity)
+		r.Vulnerabilities[severity]++
+
+		if atLeast(severity, s.FailOn) {
+			failed = append(failed, v.ID)
+		} else if atLeast(severity, s.WarnOn) {
+			r.Warnings = append(r.Warnings, fmt.Sprintf("%s vulnerability %s", severity, v.ID))
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("found vulnerabilities with severity %s or higher: %s", s.FailOn, strings.Join(failed, ", "))
+	}
+	return nil
+}
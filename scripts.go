@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// maintainerScript is a script run by the package manager and the config field it is set in
+type maintainerScript struct {
+	field string
+	path  string
+}
+
+// method scripts lists the maintainer scripts of the package
+func (p *Package) scripts() []maintainerScript {
+	scripts := []maintainerScript{}
+	for _, s := range []maintainerScript{
+		{"target.before_install", p.Target.BeforeInstall},
+		{"target.after_install", p.Target.AfterInstall},
+		{"target.before_remove", p.Target.BeforeRemove},
+		{"target.after_remove", p.Target.AfterRemove},
+		{"target.before_upgrade", p.Target.BeforeUpgrade},
+		{"target.after_upgrade", p.Target.AfterUpgrade},
+		{"target.post_extract", p.Target.PostExtract},
+	} {
+		if s.path != "" {
+			scripts = append(scripts, s)
+		}
+	}
+	return scripts
+}
+
+// function checkScript verifies the syntax of a maintainer script
+// sh -n catches syntax errors, shellcheck is used in addition when it is installed
+func checkScript(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	if output, err := exec.Command("sh", "-n", path).CombinedOutput(); err != nil {
+		return fmt.Errorf("syntax error: %s", strings.TrimSpace(string(output)))
+	}
+	if _, err := exec.LookPath("shellcheck"); err == nil {
+		if output, err := exec.Command("shellcheck", "--severity=error", path).CombinedOutput(); err != nil {
+			return fmt.Errorf("shellcheck found errors:\n%s", strings.TrimSpace(string(output)))
+		}
+	}
+	return nil
+}
+
+// method checkScripts verifies the syntax of all maintainer scripts of the package
+// a broken script fails on the hosts the package is installed to, so it has to fail the build
+// templates are checked with the template values inserted, unless they contain tags only fpm can render
+func (p *Package) checkScripts() error {
+	for _, s := range p.scripts() {
+		path := s.path
+		if p.templated() {
+			rendered, complete, err := p.renderScript(s.path)
+			if err != nil {
+				return ConfigError{
+					packageEntry: p.Name,
+					field:        s.field,
+					message:      fmt.Sprintf(tr("script %s is invalid: %s"), s.path, err),
+				}
+			}
+			defer os.Remove(rendered)
+			if !complete {
+				continue
+			}
+			path = rendered
+		}
+		if err := checkScript(path); err != nil {
+			return ConfigError{
+				packageEntry: p.Name,
+				field:        s.field,
+				message:      fmt.Sprintf(tr("script %s is invalid: %s"), s.path, err),
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Selective builds only the packages affected by the changes of a pull request
+type Selective struct {
+	// Always lists files or patterns whose change rebuilds all packages e.g. a shared build script *OPTIONAL*
+	// changes of packages.yml always rebuild all packages
+	Always []string `yaml:"always"`
+}
+
+// function matchesPath decides whether a changed file lies within an input of a package
+// inputs are files, directories or glob patterns relative to the workspace
+func matchesPath(input, changed string) bool {
+	input = filepath.Clean(input)
+	if input == "." || input == changed || strings.HasPrefix(changed, input+"/") {
+		return true
+	}
+	matched, _ := filepath.Match(input, changed)
+	return matched
+}
+
+// method inputs lists the files and directories of the workspace a package is built from
+// packages with remote sources like git or url only depend on packages.yml
+func (p *Package) inputs() []string {
+	inputs := []string{}
+	for _, s := range p.scripts() {
+		inputs = append(inputs, s.path)
+	}
+	inputs = append(inputs, p.Target.Systemd...)
+	for _, c := range []string{p.Target.Changelog, p.Target.UpstreamChangelog, p.Target.CustomControl} {
+		if c != "" {
+			inputs = append(inputs, c)
+		}
+	}
+	inputs = append(inputs, p.Target.MetaFiles...)
+	if p.Shell != nil {
+		for _, c := range p.Shell.Completions {
+			inputs = append(inputs, c)
+		}
+		if p.Shell.Profile != "" {
+			inputs = append(inputs, p.Shell.Profile)
+		}
+	}
+	if p.Source.ExcludeFile != "" {
+		inputs = append(inputs, p.Source.ExcludeFile)
+	}
+	if p.LicenseAudit != nil && p.LicenseAudit.SourceOffer != "" {
+		inputs = append(inputs, p.LicenseAudit.SourceOffer)
+	}
+	if p.Target.DescriptionFrom != nil && p.Target.DescriptionFrom.File != "" {
+		inputs = append(inputs, p.Target.DescriptionFrom.File)
+	}
+	if p.PathsFrom != "" && p.PathsFrom != "-" {
+		inputs = append(inputs, p.PathsFrom)
+	}
+	for _, s := range p.Sources {
+		if s.Dir != "" {
+			inputs = append(inputs, s.Dir)
+		}
+	}
+	inputs = append(inputs, p.Watch...)
+
+	switch p.Source.Mode {
+	case "git", "url", "docker", "empty":
+		return inputs
+	case "go":
+		return append(inputs, filepath.Join(".", p.Source.Chdir))
+	case "virtualenv":
+		if p.Source.Requirements != "" {
+			inputs = append(inputs, p.Source.Requirements)
+		}
+		return inputs
+	}
+
+	if len(p.Paths) == 0 {
+		return append(inputs, filepath.Join(".", p.Source.Chdir))
+	}
+	for _, a := range p.Paths {
+		src, _ := splitPath(a)
+		if filepath.IsAbs(src) {
+			continue
+		}
+		inputs = append(inputs, filepath.Join(p.Source.Chdir, src))
+	}
+	return inputs
+}
+
+// function changedFiles lists the files changed by the pull request that triggered the workflow
+// ok is false if the workflow was not triggered by a pull request
+func changedFiles() (files []string, ok bool, err error) {
+	base := eventField("pull_request.base.sha")
+	head := eventField("pull_request.head.sha")
+	if base == "" || head == "" {
+		return nil, false, nil
+	}
+	diff, err := workspaceGit("diff", "--name-only", base+"..."+head)
+	if err != nil {
+		return nil, true, fmt.Errorf("%s, check out the repository with fetch-depth: 0", err)
+	}
+	if diff == "" {
+		return []string{}, true, nil
+	}
+	return strings.Split(diff, "\n"), true, nil
+}
+
+// method applySelective skips packages not affected by the changes of the pull request
+// outside of pull requests and if the changes can not be determined all packages are built
+func (c *FPMConfig) applySelective(config string) error {
+	if c.Selective == nil {
+		return nil
+	}
+	changed, ok, err := changedFiles()
+	if err != nil {
+		fmt.Printf(tr("::warning::building all packages, the changed files are unknown: %s\n"), err)
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+
+	for _, f := range changed {
+		for _, pattern := range append([]string{config}, c.Selective.Always...) {
+			if matchesPath(pattern, f) {
+				fmt.Printf(tr("building all packages, %s changed\n"), f)
+				return nil
+			}
+		}
+	}
+
+	selected := []Package{}
+	for _, p := range c.Packages {
+		affected := false
+		for _, input := range p.inputs() {
+			for _, f := range changed {
+				affected = affected || matchesPath(input, f)
+			}
+		}
+		if affected {
+			selected = append(selected, p)
+		} else if !contains(c.report.Skipped, p.Name) {
+			c.report.Skipped = append(c.report.Skipped, p.Name)
+		}
+	}
+	c.Packages = selected
+
+	if len(c.report.Skipped) > 0 {
+		fmt.Printf(tr("skipping packages not affected by the pull request: %s\n"), strings.Join(c.report.Skipped, ", "))
+	}
+	if path := os.Getenv("GITHUB_OUTPUT"); path != "" {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		fmt.Fprintf(f, "skipped=%s\n", strings.Join(c.report.Skipped, ","))
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Shell installs the shell integration of command line tools to the locations the shells read it from
+type Shell struct {
+	// Completions maps the shells bash, zsh and fish to completion scripts *OPTIONAL*
+	Completions map[string]string `yaml:"completions"`
+
+	// Command is the command completed by the scripts, defaults to the package name *OPTIONAL*
+	Command string `yaml:"command"`
+
+	// Profile is a snippet sourced by login shells, installed to /etc/profile.d/<package>.sh *OPTIONAL*
+	// e.g. to extend PATH, it has to be a POSIX shell script
+	Profile string `yaml:"profile"`
+}
+
+// method command returns the command the completions belong to
+func (s *Shell) command(p *Package) string {
+	if s.Command != "" {
+		return s.Command
+	}
+	return p.Name
+}
+
+// method completionPath returns where the completion script of a shell is installed to
+// debian reads zsh completions of packages from vendor-completions, other distributions from site-functions
+func (s *Shell) completionPath(p *Package, shell string) string {
+	command := s.command(p)
+	switch shell {
+	case "bash":
+		return "/usr/share/bash-completion/completions/" + command
+	case "zsh":
+		if p.Target.Mode == "deb" {
+			return "/usr/share/zsh/vendor-completions/_" + command
+		}
+		return "/usr/share/zsh/site-functions/_" + command
+	default:
+		return "/usr/share/fish/vendor_completions.d/" + command + ".fish"
+	}
+}
+
+// function firstLine returns the first line of a file
+func firstLine(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Scan()
+	return scanner.Text(), scanner.Err()
+}
+
+// function checkCompletion verifies a completion script with the shell it is written for, if it is installed
+func checkCompletion(shell, path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	if shell == "zsh" {
+		line, err := firstLine(path)
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(line, "#compdef") {
+			return fmt.Errorf("zsh completions have to start with #compdef")
+		}
+	}
+	if _, err := exec.LookPath(shell); err != nil {
+		return nil
+	}
+	if output, err := exec.Command(shell, "-n", path).CombinedOutput(); err != nil {
+		return fmt.Errorf("syntax error: %s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// method check validates the shell integration of a package
+func (s *Shell) check(p *Package) error {
+	if strings.ContainsAny(s.command(p), "/ ") {
+		return ConfigError{
+			packageEntry: p.Name,
+			field:        "shell.command",
+			message:      "the command is a name without slashes or spaces",
+		}
+	}
+	for shell, path := range s.Completions {
+		if !contains([]string{"bash", "zsh", "fish"}, shell) {
+			return ConfigError{
+				packageEntry: p.Name,
+				field:        "shell.completions." + shell,
+				message:      "completions may be given for bash|zsh|fish",
+			}
+		}
+		if err := checkCompletion(shell, path); err != nil {
+			return ConfigError{
+				packageEntry: p.Name,
+				field:        "shell.completions." + shell,
+				message:      fmt.Sprintf(tr("completion %s is invalid: %s"), path, err),
+			}
+		}
+	}
+	if s.Profile != "" {
+		if err := checkScript(s.Profile); err != nil {
+			return ConfigError{
+				packageEntry: p.Name,
+				field:        "shell.profile",
+				message:      fmt.Sprintf(tr("profile snippet %s is invalid: %s"), s.Profile, err),
+			}
+		}
+	}
+	return nil
+}
+
+// method paths returns the path arguments installing the completions and the profile snippet, sorted by shell
+func (s *Shell) paths(p *Package) ([]string, error) {
+	shells := []string{}
+	for shell := range s.Completions {
+		shells = append(shells, shell)
+	}
+	sort.Strings(shells)
+
+	type file struct{ src, dst string }
+	files := []file{}
+	for _, shell := range shells {
+		files = append(files, file{s.Completions[shell], s.completionPath(p, shell)})
+	}
+	if s.Profile != "" {
+		files = append(files, file{s.Profile, "/etc/profile.d/" + p.Name + ".sh"})
+	}
+
+	paths := []string{}
+	for _, f := range files {
+		src, err := filepath.Abs(f.src)
+		if err != nil {
+			return nil, err
+		}
+		path, err := p.pathArgument(src, f.dst)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Signing configures how packages are signed
+// the private key does not need to be available in the runner, signing can be delegated to
+// an external command, a HashiCorp Vault transit engine or AWS KMS
+type Signing struct {
+	// Signer selects where the signing key lives *REQUIRED*
+	//
+	// "gpg": a key of the local gpg keyring, Key is the key id
+	// "command": Command is run with the data on stdin and has to print the signature on stdout
+	// "vault": a key of a vault transit engine, Key is the key name
+	// "kms": an asymmetric AWS KMS key, Key is the key id or arn, the aws cli has to be available
+	Signer string `yaml:"signer"`
+
+	// Key identifies the key for the signers gpg, vault and kms
+	Key string `yaml:"key"`
+
+	// Keys are additional keys that sign alongside Key *OPTIONAL*
+	// list the old key here while rotating to a new key, so consumers trusting either keep working
+	Keys []string `yaml:"keys"`
+
+	// Command is the shell command used by signer "command"
+	// the key is passed in the environment variable SIGNING_KEY
+	Command string `yaml:"command"`
+
+	// Address of the vault server, defaults to VAULT_ADDR
+	// the token is taken from VAULT_TOKEN
+	Address string `yaml:"address"`
+
+	// Mount is the path the transit engine is mounted at, defaults to "transit"
+	Mount string `yaml:"mount"`
+
+	// Algorithm used by vault (hash algorithm e.g. "sha2-256") and kms (e.g. "RSASSA_PKCS1_V1_5_SHA_256")
+	Algorithm string `yaml:"algorithm"`
+
+	// ReleaseFiles are Release files of apt repositories that are signed with all keys after the build *OPTIONAL*
+	// only for signer gpg and commands printing OpenPGP signatures, apt can not verify others
+	ReleaseFiles []string `yaml:"release_files"`
+
+	// Keyring builds a package installing the public keys *OPTIONAL*
+	Keyring *Keyring `yaml:"keyring"`
+}
+
+// Signer creates signatures without exposing where the key is kept
+type Signer interface {
+	// Sign returns a signature over the contents read from data
+	Sign(data io.Reader) ([]byte, error)
+}
+
+// method signer creates the Signer selected by the configuration for the primary key
+func (s *Signing) signer() Signer {
+	return s.signerFor(s.Key)
+}
+
+// method signerFor creates the Signer selected by the configuration for a key
+func (s *Signing) signerFor(key string) Signer {
+	switch s.Signer {
+	case "gpg":
+		return gpgSigner{key: key}
+	case "command":
+		return commandSigner{command: s.Command, key: key}
+	case "vault":
+		return vaultSigner{s, key}
+	case "kms":
+		return kmsSigner{s, key}
+	}
+	return nil
+}
+
+// method signers creates a Signer for the primary and every additional key
+func (s *Signing) signers() []Signer {
+	signers := []Signer{s.signer()}
+	for _, k := range s.Keys {
+		signers = append(signers, s.signerFor(k))
+	}
+	return signers
+}
+
+// method signsAPK decides whether the signer creates the RSA PKCS#1 v1.5 signatures over SHA-1 of apk packages
+// gpg creates OpenPGP packets and kms has no SHA-1, a command is trusted to print the right signature
+func (s *Signing) signsAPK() bool {
+	return s.Signer == "command" || (s.Signer == "vault" && s.Algorithm == "sha1")
+}
+
+// method check validates the signing configuration
+func (s *Signing) check() error {
+	validSigners := []string{"gpg", "command", "vault", "kms"}
+	if !contains(validSigners, s.Signer) {
+		return ConfigError{
+			field:   "signing.signer",
+			message: fmt.Sprintf(tr("signer is required and may contain %s"), strings.Join(validSigners, "|")),
+		}
+	}
+	if s.Signer == "command" && s.Command == "" {
+		return ConfigError{
+			field:   "signing.command",
+			message: "signer command requires a command",
+		}
+	}
+	if s.Signer != "command" && s.Key == "" {
+		return ConfigError{
+			field:   "signing.key",
+			message: fmt.Sprintf(tr("signer %s requires a key"), s.Signer),
+		}
+	}
+	// apt only verifies OpenPGP signatures, vault and kms create bare RSA signatures
+	if len(s.ReleaseFiles) > 0 && s.Signer != "gpg" && s.Signer != "command" {
+		return ConfigError{
+			field:   "signing.release_files",
+			message: "release files need OpenPGP signatures, use signer gpg or a command printing OpenPGP signatures",
+		}
+	}
+	if s.Keyring != nil {
+		return s.Keyring.check()
+	}
+	return nil
+}
+
+// gpgSigner creates detached binary signatures with a key of the local keyring
+type gpgSigner struct {
+	key string
+}
+
+// method Sign implements Signer
+func (g gpgSigner) Sign(data io.Reader) ([]byte, error) {
+	cmd := exec.Command("gpg", "--batch", "--yes", "--local-user", g.key, "--detach-sign", "--output", "-")
+	cmd.Stdin = data
+	cmd.Stderr = os.Stderr
+	return cmd.Output()
+}
+
+// commandSigner delegates signing to an external command
+type commandSigner struct {
+	command string
+	key     string
+}
+
+// method Sign implements Signer
+func (c commandSigner) Sign(data io.Reader) ([]byte, error) {
+	cmd := exec.Command("sh", "-c", c.command)
+	cmd.Env = append(os.Environ(), "SIGNING_KEY="+c.key)
+	cmd.Stdin = data
+	cmd.Stderr = os.Stderr
+	signature, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("signing command failed: %s", err)
+	}
+	return signature, nil
+}
+
+// rsaSigner signs with a local RSA key using PKCS #1 v1.5 and SHA-1 like abuild-sign does
+type rsaSigner struct {
+	key *rsa.PrivateKey
+}
+
+// function newRSASigner parses a PEM encoded PKCS #1 or PKCS #8 RSA private key
+func newRSASigner(key []byte) (rsaSigner, error) {
+	block, _ := pem.Decode(key)
+	if block == nil {
+		return rsaSigner{}, fmt.Errorf("key is not a PEM encoded private key")
+	}
+	if k, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return rsaSigner{k}, nil
+	}
+	k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return rsaSigner{}, err
+	}
+	rsaKey, ok := k.(*rsa.PrivateKey)
+	if !ok {
+		return rsaSigner{}, fmt.Errorf("key must be an RSA private key")
+	}
+	return rsaSigner{rsaKey}, nil
+}
+
+// method Sign implements Signer
+func (r rsaSigner) Sign(data io.Reader) ([]byte, error) {
+	h := sha1.New()
+	if _, err := io.Copy(h, data); err != nil {
+		return nil, err
+	}
+	return rsa.SignPKCS1v15(nil, r.key, crypto.SHA1, h.Sum(nil))
+}
+
+// vaultSigner signs using the transit secrets engine of HashiCorp Vault
+type vaultSigner struct {
+	*Signing
+	key string
+}
+
+// method Sign implements Signer
+// the digest is computed locally and sent prehashed, so the artifact is not uploaded to vault
+func (v vaultSigner) Sign(data io.Reader) ([]byte, error) {
+	address := v.Address
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+	mount := v.Mount
+	if mount == "" {
+		mount = "transit"
+	}
+	algorithm := v.Algorithm
+	if algorithm == "" {
+		algorithm = "sha2-256"
+	}
+
+	h, err := digestOf(algorithm, data)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"input":               base64.StdEncoding.EncodeToString(h),
+		"prehashed":           true,
+		"signature_algorithm": "pkcs1v15",
+	})
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("%s/v1/%s/sign/%s/%s", strings.TrimSuffix(address, "/"), mount, v.key, algorithm)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", os.Getenv("VAULT_TOKEN"))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	contents, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault signing failed: %s %s", resp.Status, contents)
+	}
+
+	result := struct {
+		Data struct {
+			Signature string `json:"signature"`
+		} `json:"data"`
+	}{}
+	if err := json.Unmarshal(contents, &result); err != nil {
+		return nil, err
+	}
+
+	// signatures are returned as vault:v<key version>:<base64 signature>
+	parts := strings.Split(result.Data.Signature, ":")
+	return base64.StdEncoding.DecodeString(parts[len(parts)-1])
+}
+
+// function digestOf hashes data with a hash algorithm of vault like sha2-256
+func digestOf(algorithm string, data io.Reader) ([]byte, error) {
+	hashes := map[string]func() hash.Hash{
+		"sha1":     sha1.New,
+		"sha2-224": sha256.New224,
+		"sha2-256": sha256.New,
+		"sha2-384": sha512.New384,
+		"sha2-512": sha512.New,
+	}
+	newHash, ok := hashes[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("unsupported vault hash algorithm %s", algorithm)
+	}
+	h := newHash()
+	if _, err := io.Copy(h, data); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// kmsSigner signs using an asymmetric AWS KMS key through the aws cli
+type kmsSigner struct {
+	*Signing
+	key string
+}
+
+// method Sign implements Signer
+// KMS limits messages to 4 KiB, so the digest is computed locally and signed as message type DIGEST
+func (k kmsSigner) Sign(data io.Reader) ([]byte, error) {
+	algorithm := k.Algorithm
+	if algorithm == "" {
+		algorithm = "RSASSA_PKCS1_V1_5_SHA_256"
+	}
+
+	var h hash.Hash
+	switch {
+	case strings.HasSuffix(algorithm, "SHA_256"):
+		h = sha256.New()
+	case strings.HasSuffix(algorithm, "SHA_384"):
+		h = sha512.New384()
+	case strings.HasSuffix(algorithm, "SHA_512"):
+		h = sha512.New()
+	default:
+		return nil, fmt.Errorf("unsupported kms signing algorithm %s", algorithm)
+	}
+	if _, err := io.Copy(h, data); err != nil {
+		return nil, err
+	}
+
+	digest, err := ioutil.TempFile("", "digest-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(digest.Name())
+	if _, err := digest.Write(h.Sum(nil)); err != nil {
+		return nil, err
+	}
+	digest.Close()
+
+	cmd := exec.Command("aws", "kms", "sign",
+		"--key-id", k.key,
+		"--message", "fileb://"+digest.Name(),
+		"--message-type", "DIGEST",
+		"--signing-algorithm", algorithm,
+		"--output", "text", "--query", "Signature")
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("aws kms sign failed: %s", err)
+	}
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(string(output)))
+}
+
+// function signArtifact writes detached signatures of a package file
+// the signature of the primary key is written to <artifact>.sig, the signatures of
+// additional keys to <artifact>.<n>.sig. the file is streamed to every signer, packages may not fit in memory
+func signArtifact(signers []Signer, artifact string) ([]string, error) {
+	paths := []string{}
+	for i, s := range signers {
+		f, err := os.Open(artifact)
+		if err != nil {
+			return nil, err
+		}
+		signature, err := s.Sign(f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		path := artifact + ".sig"
+		if i > 0 {
+			path = fmt.Sprintf("%s.%d.sig", artifact, i)
+		}
+		if err := ioutil.WriteFile(path, signature, 0644); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
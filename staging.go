@@ -0,0 +1,269 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SourceSpec is one of several sources merged into the staging tree of a composite package
+// every spec sets exactly one of Dir, URL and Content
+type SourceSpec struct {
+	// Dir is a local directory whose contents are installed below Prefix
+	Dir string `yaml:"dir"`
+	// Prefix is the path the contents of Dir are installed to, defaults to /
+	Prefix string `yaml:"prefix"`
+	// Excludes are patterns of files in Dir that are not installed
+	Excludes []string `yaml:"excludes"`
+
+	// URL is downloaded and installed to Target
+	URL string `yaml:"url"`
+	// SHA256 is the expected checksum of the download *OPTIONAL*
+	SHA256 string `yaml:"sha256"`
+
+	// Content is written to Target e.g. a generated configuration file
+	Content string `yaml:"content"`
+
+	// Target is the absolute path URL and Content are installed to
+	Target string `yaml:"target"`
+	// FileMode is the octal mode of the file created for URL and Content, defaults to 0644
+	FileMode string `yaml:"file_mode"`
+}
+
+// method kinds counts how many of dir, url and content are set
+func (s *SourceSpec) kinds() int {
+	n := 0
+	for _, v := range []string{s.Dir, s.URL, s.Content} {
+		if v != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// method check validates a source spec, i is its index in sources
+func (s *SourceSpec) check(name string, i int) error {
+	field := fmt.Sprintf("sources[%d]", i)
+	if s.kinds() != 1 {
+		return ConfigError{
+			packageEntry: name,
+			field:        field,
+			message:      "every source needs exactly one of dir, url and content",
+		}
+	}
+	if s.Dir == "" && s.Target == "" {
+		return ConfigError{
+			packageEntry: name,
+			field:        field + ".target",
+			message:      "sources with url or content require a target path",
+		}
+	}
+	if _, err := strconv.ParseUint(s.FileMode, 8, 32); s.FileMode != "" && err != nil {
+		return ConfigError{
+			packageEntry: name,
+			field:        field + ".file_mode",
+			message:      "the file mode must be an octal number like 0755",
+		}
+	}
+	return nil
+}
+
+// method mode returns the file mode of files created for url and content
+func (s *SourceSpec) mode() os.FileMode {
+	if m, err := strconv.ParseUint(s.FileMode, 8, 32); err == nil && s.FileMode != "" {
+		return os.FileMode(m)
+	}
+	return 0644
+}
+
+// client of downloads, the timeout covers reading the whole body so a stalled server does not hang the build
+var downloadClient = &http.Client{Timeout: 10 * time.Minute}
+
+// function download fetches url to dst and verifies its sha256 checksum if one is given
+func download(url, checksum, dst string, mode os.FileMode) (string, error) {
+	resp, err := downloadClient.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("download of %s failed: %s", url, resp.Status)
+	}
+
+	f, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, h), resp.Body); err != nil {
+		return "", err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	if checksum != "" && sum != checksum {
+		return "", fmt.Errorf("checksum of %s is %s, expected %s", url, sum, checksum)
+	}
+	return sum, nil
+}
+
+// function copyFile copies a regular file or symlink keeping its mode
+func copyFile(src, dst string, info os.FileInfo) error {
+	if info.Mode()&os.ModeSymlink != 0 {
+		link, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(link, dst)
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	_, err = io.Copy(out, in)
+	return err
+}
+
+// method stage merges the sources of a composite package into a staging directory
+// a target path may only be provided by a single source, conflicts fail the build
+func (p *Package) stage() (string, error) {
+	staging, err := ioutil.TempDir("", "staging-")
+	if err != nil {
+		return "", err
+	}
+
+	// origins maps every staged path to the source providing it
+	origins := map[string]string{}
+	place := func(target, origin string, write func(dst string) error) error {
+		target = filepath.Clean("/" + target)
+		if previous, ok := origins[target]; ok {
+			return fmt.Errorf("%s is provided by %s and %s", target, previous, origin)
+		}
+		origins[target] = origin
+
+		dst := filepath.Join(staging, target)
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		return write(dst)
+	}
+
+	for i, s := range p.Sources {
+		s := s
+		origin := fmt.Sprintf("sources[%d]", i)
+		switch {
+		case s.Dir != "":
+			origin += " " + s.Dir
+			err = filepath.Walk(s.Dir, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				rel, err := filepath.Rel(s.Dir, path)
+				if err != nil {
+					return err
+				}
+				if rel != "." && excluded(rel, s.Excludes) {
+					if info.IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				if info.IsDir() {
+					return nil
+				}
+				return place(filepath.Join(s.Prefix, rel), origin, func(dst string) error {
+					return copyFile(path, dst, info)
+				})
+			})
+		case s.URL != "":
+			err = place(s.Target, origin+" "+s.URL, func(dst string) error {
+				sum, err := download(s.URL, s.SHA256, dst, s.mode())
+				if err != nil {
+					return err
+				}
+				p.remotes = append(p.remotes, remoteSource{"url " + s.URL, "sha256:" + sum})
+				return nil
+			})
+		default:
+			err = place(s.Target, origin, func(dst string) error {
+				return ioutil.WriteFile(dst, []byte(s.Content), s.mode())
+			})
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return staging, nil
+}
+
+// method importTarball extracts the tarball of source mode tar keeping its ownership and modes
+// and turns the package into a dir package of the extracted files
+func (p *Package) importTarball() error {
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("preserving the ownership requires running as root")
+	}
+	dir, err := ioutil.TempDir("", "tarball-")
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command("tar", "--same-owner", "--same-permissions", "--numeric-owner", "-xf", p.Paths[0], "-C", dir)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s\n%s", err, output)
+	}
+
+	p.Source.Mode = "dir"
+	p.Source.Chdir = dir
+	p.Paths = []string{"."}
+	return nil
+}
+
+// method fetch downloads the file of source mode url and turns the package into a dir package
+// tarballs are extracted, other files are made executable
+func (p *Package) fetch() error {
+	fmt.Printf(tr("downloading %s...\n"), p.Source.URL)
+	dir, err := ioutil.TempDir("", "download-")
+	if err != nil {
+		return err
+	}
+	name := filepath.Base(strings.SplitN(p.Source.URL, "?", 2)[0])
+	file := filepath.Join(dir, name)
+	sum, err := download(p.Source.URL, p.Source.SHA256, file, 0755)
+	if err != nil {
+		return err
+	}
+	p.remotes = append(p.remotes, remoteSource{"url " + p.Source.URL, "sha256:" + sum})
+
+	if tarballPattern.MatchString(name) {
+		extracted := filepath.Join(dir, "extracted")
+		if err := os.Mkdir(extracted, 0755); err != nil {
+			return err
+		}
+		if output, err := exec.Command("tar", "-xf", file, "-C", extracted).CombinedOutput(); err != nil {
+			return fmt.Errorf("%s\n%s", err, output)
+		}
+		dir = extracted
+	}
+
+	p.Source.Mode = "dir"
+	p.Source.Chdir = dir
+	if len(p.Paths) == 0 {
+		p.Paths = []string{"."}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// event holds the payload of the GitHub event that triggered the workflow
+// it is loaded on first use, nil means there is no payload available
+var event map[string]interface{}
+var eventLoaded bool
+
+// shortcuts to frequently used fields of the event payload
+var eventShortcuts = map[string][]string{
+	"github.pr_number":    {"pull_request.number", "number"},
+	"github.release_name": {"release.name"},
+	"github.release_tag":  {"release.tag_name"},
+}
+
+// function loadEvent reads the event payload from GITHUB_EVENT_PATH
+func loadEvent() map[string]interface{} {
+	if eventLoaded {
+		return event
+	}
+	eventLoaded = true
+
+	contents, err := ioutil.ReadFile(os.Getenv("GITHUB_EVENT_PATH"))
+	if err != nil {
+		return nil
+	}
+	if err := json.Unmarshal(contents, &event); err != nil {
+		fmt.Printf("could not parse GitHub event payload: %s\n", err)
+		event = nil
+	}
+	return event
+}
+
+// function eventField looks up a dotted path like "release.tag_name" in the event payload
+// values that are not found or not scalar result in an empty string
+func eventField(path string) string {
+	var value interface{} = loadEvent()
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		value = object[key]
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
+
+// function expandVariable resolves a ${variable} of packages.yml
+//
+// "github.event.<path>" is looked up in the event payload
+// "github.inputs.<name>" resolves to an input of a workflow_dispatch event
+// shortcuts like "github.release_tag" resolve to the first non-empty of their event fields
+// references to other packages are kept and resolved after all packages are read
+// everything else is taken from the environment
+func expandVariable(name string) string {
+	if isReference(name) {
+		return "${" + name + "}"
+	}
+	if paths, ok := eventShortcuts[name]; ok {
+		for _, p := range paths {
+			if v := eventField(p); v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+	if strings.HasPrefix(name, "github.event.") {
+		return eventField(strings.TrimPrefix(name, "github.event."))
+	}
+	if strings.HasPrefix(name, "github.inputs.") {
+		return eventField("inputs." + strings.TrimPrefix(name, "github.inputs."))
+	}
+	return os.Getenv(name)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+)
+
+// keys of template values become ruby methods, so they have to be identifiers
+var templateKeyPattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// tags of templates like "<%= service %>" or "<%- if x -%>", the first group is "=" for tags printing a value
+var templateTag = regexp.MustCompile(`<%(=|-)?\s*(.*?)\s*-?%>`)
+
+// method templated decides whether fpm renders the scripts of the package as templates
+func (p *Package) templated() bool {
+	return p.Target.TemplateScripts || len(p.Target.TemplateValues) > 0
+}
+
+// method checkTemplateValues validates the keys of the template values
+func (p *Package) checkTemplateValues() error {
+	for key := range p.Target.TemplateValues {
+		if !templateKeyPattern.MatchString(key) {
+			return ConfigError{
+				packageEntry: p.Name,
+				field:        "target.template_values." + key,
+				message:      "keys of template values may contain lower case letters, digits and underscores",
+			}
+		}
+	}
+	return nil
+}
+
+// method templateValueArgs returns the fpm arguments passing the template values, sorted by key
+func (p *Package) templateValueArgs() []string {
+	keys := []string{}
+	for key := range p.Target.TemplateValues {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	args := []string{}
+	if p.templated() {
+		args = append(args, "--template-scripts")
+	}
+	for _, key := range keys {
+		args = append(args, "--template-value", fmt.Sprintf("%s=%s", key, p.Target.TemplateValues[key]))
+	}
+	return args
+}
+
+// method renderScript writes a copy of a script template with the values of the package inserted
+//
+// only tags printing a template value, the name or the version are replaced. complete is false if other
+// tags remain, e.g. conditions, which only fpm can render
+func (p *Package) renderScript(path string) (string, bool, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", false, err
+	}
+	values := map[string]string{"name": p.Name, "version": p.Target.Version}
+	for key, value := range p.Target.TemplateValues {
+		values[key] = value
+	}
+
+	complete := true
+	rendered := templateTag.ReplaceAllStringFunc(string(contents), func(tag string) string {
+		groups := templateTag.FindStringSubmatch(tag)
+		if value, ok := values[groups[2]]; ok && groups[1] == "=" {
+			return value
+		}
+		complete = false
+		return tag
+	})
+
+	f, err := ioutil.TempFile("", "script-")
+	if err != nil {
+		return "", false, err
+	}
+	defer f.Close()
+	_, err = f.WriteString(rendered)
+	return f.Name(), complete, err
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Train coordinates a joint release of several configurations, e.g. of a product suite shipped together
+// all configurations are built before anything is published, they are published in the listed order
+type Train struct {
+	// Version shared by all packages of all configurations *REQUIRED*
+	Version string `yaml:"version"`
+
+	// Configs lists the configurations of the train in publish order *REQUIRED*
+	Configs []TrainConfig `yaml:"configs"`
+
+	// ReleaseNotes is the markdown file the combined release notes of all configurations are written to *OPTIONAL*
+	ReleaseNotes string `yaml:"release_notes"`
+
+	// Attach lists where the combined release notes are published to, like release_notes.attach *OPTIONAL*
+	Attach []string `yaml:"attach"`
+}
+
+// TrainConfig is a single configuration of a release train
+type TrainConfig struct {
+	// Name identifies the configuration in logs and the release notes *REQUIRED*
+	Name string `yaml:"name"`
+
+	// Repo is the url of a repository containing the configuration *OPTIONAL*
+	// without it the configuration is read from the workspace
+	Repo string `yaml:"repo"`
+
+	// Ref is the branch, tag or commit of Repo, defaults to the default branch
+	Ref string `yaml:"ref"`
+
+	// Path of the configuration file, defaults to packages.yml
+	// paths of the configuration are relative to its directory
+	Path string `yaml:"path"`
+
+	// dir is the directory the configuration is built in
+	dir string
+
+	// config is the loaded configuration
+	config *FPMConfig
+}
+
+// method check validates the manifest of the train
+func (t *Train) check() error {
+	if t.Version == "" || len(t.Configs) == 0 {
+		return ConfigError{
+			field:   "train",
+			message: "a version and at least one configuration are required",
+		}
+	}
+	names := []string{}
+	for i, tc := range t.Configs {
+		if tc.Name == "" || contains(names, tc.Name) {
+			return ConfigError{
+				field:   fmt.Sprintf("configs[%d].name", i),
+				message: "every configuration needs a unique name",
+			}
+		}
+		names = append(names, tc.Name)
+		if tc.Ref != "" && tc.Repo == "" {
+			return ConfigError{
+				field:   fmt.Sprintf("configs[%d].ref", i),
+				message: "ref is only available with repo",
+			}
+		}
+	}
+	return nil
+}
+
+// function inDir runs f with dir as working directory
+func inDir(dir string, f func() error) error {
+	previous, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if err := os.Chdir(dir); err != nil {
+		return err
+	}
+	defer os.Chdir(previous)
+	return f()
+}
+
+// method load fetches and reads the configuration and sets the version of the train
+// with a public key the configuration has to carry a valid signature like a single packages.yml
+func (tc *TrainConfig) load(version, runID string, publicKey []byte) error {
+	root, err := filepath.Abs(".")
+	if err != nil {
+		return err
+	}
+	if tc.Repo != "" {
+		fmt.Printf("cloning %s %s...\n", tc.Repo, tc.Ref)
+		if root, err = clone(tc.Repo, tc.Ref); err != nil {
+			return err
+		}
+	}
+	path := tc.Path
+	if path == "" {
+		path = "packages.yml"
+	}
+	tc.dir = filepath.Join(root, filepath.Dir(path))
+
+	c := &FPMConfig{report: Report{RunID: runID}}
+	tc.config = c
+	return inDir(tc.dir, func() error {
+		if publicKey != nil {
+			if err := verifyConfigWith(filepath.Base(path), publicKey, ""); err != nil {
+				return err
+			}
+		}
+		err := c.ReadFile(filepath.Base(path))
+		c.collectSecrets()
+		if err != nil {
+			return err
+		}
+		if err := c.addKeyring(); err != nil {
+			return err
+		}
+		if err := c.override(version, "", "", "", ""); err != nil {
+			return err
+		}
+		if err := c.resolveReferences(); err != nil {
+			return err
+		}
+		c.order()
+		return c.check()
+	})
+}
+
+// function runTrain builds and publishes all configurations of the release train in the manifest at path
+//
+// a failing build stops the train before anything is published, a failing publish stops publishing
+// the following configurations. the combined results are summarized like for a single configuration
+func runTrain(path, key string, dryRun, verifyCredentials bool) {
+	summary := &FPMConfig{report: Report{RunID: newRunID()}}
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		fmt.Printf("could not read release train: %s\n", err)
+		summary.finish(1)
+	}
+	t := Train{}
+	if err := yaml.UnmarshalStrict([]byte(os.Expand(string(contents), expandVariable)), &t); err != nil {
+		fmt.Printf("could not parse release train: %s\n", err)
+		summary.finish(1)
+	}
+	if err := t.check(); err != nil {
+		fmt.Println(err)
+		summary.finish(1)
+	}
+
+	// the key is read once, the configurations are loaded from their own directories
+	var publicKey []byte
+	if configKeyConfigured(key) {
+		if publicKey, err = configKey(key); err != nil {
+			fmt.Printf("could not read the public key: %s\n", err)
+			summary.finish(1)
+		}
+	}
+
+	for i := range t.Configs {
+		tc := &t.Configs[i]
+		if err := tc.load(t.Version, summary.report.RunID, publicKey); err != nil {
+			fmt.Printf("configuration %s: %s\n", tc.Name, strings.TrimSpace(err.Error()))
+			summary.finish(1)
+		}
+	}
+
+	// build everything first, a failing build exits before anything is published
+	notes := &strings.Builder{}
+	for _, tc := range t.Configs {
+		fmt.Printf("building configuration %s %s...\n", tc.Name, t.Version)
+		c := tc.config
+		err := inDir(tc.dir, func() error {
+			if err := c.build(); err != nil {
+				return err
+			}
+			if c.ReleaseNotes == nil {
+				c.ReleaseNotes = &ReleaseNotes{}
+			}
+			n, err := c.renderNotes(tc.Name + " " + t.Version)
+			notes.WriteString(n)
+			return err
+		})
+		if err != nil {
+			fmt.Printf("configuration %s: %s\n", tc.Name, err)
+			summary.finish(3)
+		}
+	}
+
+	// combined release notes of all configurations
+	if err := (&ReleaseNotes{Output: t.ReleaseNotes, Attach: t.Attach}).publishNotes(notes.String()); err != nil {
+		fmt.Printf("could not create release notes: %s\n", err)
+		summary.finish(3)
+	}
+
+	// publish in the order of the manifest
+	code := 0
+	for _, tc := range t.Configs {
+		c := tc.config
+		err := inDir(tc.dir, func() error {
+			if c.Signing != nil {
+				if err := c.Signing.signReleaseFiles(); err != nil {
+					return err
+				}
+			}
+			published := c.publish(dryRun, verifyCredentials)
+			if err := c.writeReport(); err != nil {
+				return err
+			}
+			return published
+		})
+		summary.report.Packages = append(summary.report.Packages, c.report.Packages...)
+		if err != nil {
+			fmt.Printf("publishing configuration %s failed, the following configurations are not published: %s\n", tc.Name, err)
+			code = 4
+			break
+		}
+	}
+	summary.finish(code)
+}
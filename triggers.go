@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// directives of dpkg triggers files and the fpm flags declaring them
+var triggerFlags = map[string]string{
+	"interest":         "--deb-interest",
+	"interest-await":   "--deb-interest",
+	"interest-noawait": "--deb-interest-noawait",
+	"activate":         "--deb-activate",
+	"activate-await":   "--deb-activate",
+	"activate-noawait": "--deb-activate-noawait",
+}
+
+// function splitTrigger splits a line of a triggers file like "activate-noawait ldconfig" into directive and name
+func splitTrigger(t string) (string, string, error) {
+	fields := strings.Fields(t)
+	if len(fields) != 2 {
+		return "", "", fmt.Errorf("%q has to consist of a directive and the name of the trigger", t)
+	}
+	if _, ok := triggerFlags[fields[0]]; !ok {
+		return "", "", fmt.Errorf("%q starts with an unknown directive, valid are "+
+			"interest|interest-await|interest-noawait|activate|activate-await|activate-noawait", t)
+	}
+	return fields[0], fields[1], nil
+}
+
+// method checkTriggers validates the dpkg triggers of the package
+func (p *Package) checkTriggers() error {
+	for _, t := range p.Target.Triggers {
+		if _, _, err := splitTrigger(t); err != nil {
+			return ConfigError{
+				packageEntry: p.Name,
+				field:        "target.triggers",
+				message:      err.Error(),
+			}
+		}
+	}
+	for _, m := range p.Target.MetaFiles {
+		if len(p.Target.Triggers) > 0 && filepath.Base(m) == "triggers" {
+			return ConfigError{
+				packageEntry: p.Name,
+				field:        "target.triggers",
+				message:      fmt.Sprintf(tr("triggers can not be combined with the meta file %s"), m),
+			}
+		}
+	}
+	return nil
+}
+
+// method activates decides whether the package activates a trigger
+func (p *Package) activates(trigger string) bool {
+	for _, t := range p.Target.Triggers {
+		directive, name, err := splitTrigger(t)
+		if err == nil && strings.HasPrefix(directive, "activate") && name == trigger {
+			return true
+		}
+	}
+	return false
+}
+
+// method triggerArgs returns the fpm arguments declaring the triggers of the package
+func (p *Package) triggerArgs() []string {
+	args := []string{}
+	for _, t := range p.Target.Triggers {
+		if directive, name, err := splitTrigger(t); err == nil {
+			args = append(args, triggerFlags[directive], name)
+		}
+	}
+	return args
+}
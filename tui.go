@@ -0,0 +1,218 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// number of log lines shown below the package list
+const logPaneLines = 12
+
+// terminalUI shows the progress of every package and the latest log lines for local runs
+// the complete log is written to a file, the view is redrawn in place using ANSI escape codes
+type terminalUI struct {
+	mutex   sync.Mutex
+	console *os.File
+	log     *os.File
+	width   int
+
+	packages  []string
+	states    []string
+	artifacts []string
+	started   []time.Time
+	elapsed   []time.Duration
+	current   int
+
+	lines []string
+	// drawn counts the lines of the last redraw, they are cleared before drawing again
+	drawn int
+	frame int
+	begin time.Time
+	done  chan struct{}
+}
+
+// ui is the terminal view of the run, it is nil unless --tui is given
+var ui *terminalUI
+
+// function startUI takes over the console, the output of the action is written to the log pane and logPath
+func startUI(console *os.File, logPath string, packages []Package) (*terminalUI, error) {
+	log, err := os.Create(logPath)
+	if err != nil {
+		return nil, err
+	}
+	width := terminalWidth()
+
+	u := &terminalUI{
+		console: console,
+		log:     log,
+		width:   width,
+		current: -1,
+		begin:   time.Now(),
+		done:    make(chan struct{}),
+	}
+	for _, p := range packages {
+		u.packages = append(u.packages, fmt.Sprintf("%s (%s)", p.Name, p.Target.Mode))
+		u.artifacts = append(u.artifacts, "")
+		u.states = append(u.states, "waiting")
+		u.started = append(u.started, time.Time{})
+		u.elapsed = append(u.elapsed, 0)
+	}
+
+	// redraw regularly so the spinner and the elapsed time move while fpm is quiet
+	go func() {
+		ticker := time.NewTicker(200 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				u.mutex.Lock()
+				u.frame++
+				u.draw()
+				u.mutex.Unlock()
+			case <-u.done:
+				return
+			}
+		}
+	}()
+	redaction.setTap(u)
+	return u, nil
+}
+
+// function terminalWidth asks stty for the columns of the terminal, COLUMNS and 100 are the fallbacks
+func terminalWidth() int {
+	cmd := exec.Command("stty", "size")
+	cmd.Stdin = os.Stdin
+	if output, err := cmd.Output(); err == nil {
+		if size := strings.Fields(string(output)); len(size) == 2 {
+			if width, err := strconv.Atoi(size[1]); err == nil && width > 0 {
+				return width
+			}
+		}
+	}
+	if width, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && width > 0 {
+		return width
+	}
+	return 100
+}
+
+// method Write implements io.Writer, it receives the masked output of the action
+func (u *terminalUI) Write(b []byte) (int, error) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.log.Write(b)
+	for _, line := range strings.Split(strings.TrimRight(string(b), "\n"), "\n") {
+		u.lines = append(u.lines, strings.ReplaceAll(strings.TrimRight(line, "\r"), "\t", "    "))
+	}
+	if len(u.lines) > logPaneLines {
+		u.lines = u.lines[len(u.lines)-logPaneLines:]
+	}
+	u.draw()
+	return len(b), nil
+}
+
+// method building marks the package i as being built
+func (u *terminalUI) building(i int) {
+	if u == nil {
+		return
+	}
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.current = i
+	u.states[i] = "building"
+	u.started[i] = time.Now()
+	u.draw()
+}
+
+// method built marks the package currently being built with the status of its report
+func (u *terminalUI) built(r PackageReport) {
+	if u == nil {
+		return
+	}
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	if u.current < 0 {
+		return
+	}
+	u.states[u.current] = r.Status
+	u.artifacts[u.current] = r.Artifact
+	u.elapsed[u.current] = time.Since(u.started[u.current])
+	u.current = -1
+	u.draw()
+}
+
+// method clip shortens a line to the width of the terminal, so every line takes a single row when redrawing
+func (u *terminalUI) clip(line string) string {
+	if r := []rune(line); len(r) > u.width-1 {
+		return string(r[:u.width-2]) + "…"
+	}
+	return line
+}
+
+// method draw replaces the previously drawn view, the mutex has to be held
+func (u *terminalUI) draw() {
+	b := &strings.Builder{}
+	if u.drawn > 0 {
+		fmt.Fprintf(b, "\x1b[%dA", u.drawn)
+	}
+	b.WriteString("\x1b[J")
+
+	lines := []string{}
+	spinner := `-\|/`
+	for i, name := range u.packages {
+		// leave room for the status and the elapsed time
+		if r := []rune(name); len(r) > u.width-20 && u.width > 40 {
+			name = string(r[:u.width-21]) + "…"
+		}
+		var line string
+		switch u.states[i] {
+		case "waiting":
+			line = fmt.Sprintf("   %s", name)
+		case "building":
+			line = fmt.Sprintf(" %c \x1b[1m%s\x1b[0m %s", spinner[u.frame%len(spinner)], name,
+				time.Since(u.started[i]).Round(time.Second))
+		case "success":
+			line = fmt.Sprintf(" \x1b[32m✔\x1b[0m %s %s", name, u.elapsed[i].Round(time.Millisecond))
+		default:
+			line = fmt.Sprintf(" \x1b[31m✘\x1b[0m %s %s", name, u.elapsed[i].Round(time.Millisecond))
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, "\x1b[2m"+strings.Repeat("─", u.width-1)+"\x1b[0m")
+	for _, l := range u.lines {
+		lines = append(lines, "\x1b[2m"+u.clip(l)+"\x1b[0m")
+	}
+	for _, l := range lines {
+		b.WriteString(l + "\n")
+	}
+	u.drawn = len(lines)
+	io.WriteString(u.console, b.String())
+}
+
+// method close prints a summary of the run, it is called once all output went through the view
+func (u *terminalUI) close() {
+	if u == nil {
+		return
+	}
+	close(u.done)
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.lines = nil
+	u.draw()
+	u.log.Close()
+
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "\n%-40s %-8s %s\n", "package", "status", "artifact")
+	for i, name := range u.packages {
+		fmt.Fprintf(b, "%-40s %-8s %s\n", name, u.states[i], u.artifacts[i])
+	}
+	fmt.Fprintf(b, "\nfinished after %s, the complete log is %s\n\n",
+		time.Since(u.begin).Round(time.Second), filepath.Clean(u.log.Name()))
+	io.WriteString(u.console, b.String())
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// settings of systemd units whose values start with the program to run
+var execSettings = []string{"ExecStart", "ExecStartPre", "ExecStartPost", "ExecReload", "ExecStop", "ExecStopPost", "ExecCondition"}
+
+// directories of programs provided by the system or by dependencies of the package
+var systemBinDirs = []string{"/bin", "/sbin", "/usr/bin", "/usr/sbin", "/usr/libexec", "/lib/systemd", "/usr/lib/systemd"}
+
+// function unitPrograms returns the programs the Exec settings of a systemd unit file run
+func unitPrograms(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	programs := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		parts := strings.SplitN(strings.TrimSpace(scanner.Text()), "=", 2)
+		if len(parts) != 2 || !contains(execSettings, strings.TrimSpace(parts[0])) {
+			continue
+		}
+		fields := strings.Fields(parts[1])
+		if len(fields) == 0 {
+			continue
+		}
+		// prefixes like "-" or "+" change how the program is run, they are not part of the path
+		program := strings.TrimLeft(fields[0], "@-:+!")
+		if program != "" {
+			programs = append(programs, program)
+		}
+	}
+	return programs, scanner.Err()
+}
+
+// function systemProgram decides whether program is in a directory of system programs
+func systemProgram(program string) bool {
+	for _, dir := range systemBinDirs {
+		if within(dir, program) {
+			return true
+		}
+	}
+	return false
+}
+
+// method checkUnits warns about systemd units running programs that are neither part of the package
+// nor installed to a directory of system programs, e.g. a unit pointing at /usr/local/bin instead of /usr/bin
+func (p *Package) checkUnits() ([]string, error) {
+	files, err := p.contents()
+	if err != nil || len(files) == 0 {
+		return nil, err
+	}
+
+	installed := map[string]bool{}
+	units := append([]string{}, p.Target.Systemd...)
+	for _, f := range files {
+		installed[f.Target] = true
+		if strings.Contains(f.Target, "/systemd/system/") && strings.HasSuffix(f.Target, ".service") && f.Info.Mode().IsRegular() {
+			units = append(units, f.Source)
+		}
+	}
+
+	warnings := []string{}
+	for _, unit := range units {
+		programs, err := unitPrograms(unit)
+		if err != nil {
+			return nil, err
+		}
+		for _, program := range programs {
+			// programs without a path are looked up in the system directories by systemd
+			if !filepath.IsAbs(program) || installed[program] || systemProgram(program) {
+				continue
+			}
+
+			warning := fmt.Sprintf("systemd unit %s runs %s which is not part of the package", filepath.Base(unit), program)
+			for _, f := range files {
+				if path.Base(f.Target) == path.Base(program) {
+					warning += fmt.Sprintf(", did you mean %s?", f.Target)
+					break
+				}
+			}
+			warnings = append(warnings, warning)
+		}
+	}
+	return warnings, nil
+}